@@ -0,0 +1,423 @@
+package wallpaper
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+// minFrameInterval is the shortest time between two wallpaper changes of PlayAnimation,
+// since every change goes through the desktop's settings daemon.
+const minFrameInterval = time.Second
+
+type animationFrame struct {
+	img   *image.RGBA
+	delay time.Duration
+}
+
+// PlayAnimation shows the frames of an animated GIF, PNG or WebP file as a slideshow until ctx is canceled,
+// for desktops that only display still images.
+// Frames are written to the cache directory once, named after their contents, so that desktops notice every change.
+// The wallpaper changes at most once per minInterval; frames due in between are skipped to keep the animation's pace.
+// A minInterval below one second is raised to one second.
+func PlayAnimation(ctx context.Context, file string, minInterval time.Duration, effects ...Effect) error {
+	if minInterval < minFrameInterval {
+		minInterval = minFrameInterval
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	frames, err := decodeAnimation(data)
+	if err != nil {
+		return err
+	}
+
+	files, err := writeFrames(frames)
+	if err != nil {
+		return err
+	}
+
+	if len(frames) == 1 {
+		err = SetFromFileWithEffects(files[0], effects...)
+		if err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return nil
+	}
+
+	var total time.Duration
+	for _, frame := range frames {
+		total += frame.delay
+	}
+
+	start := time.Now()
+	shown := ""
+	for {
+		// find the frame due now and when the next one is
+		elapsed := time.Since(start) % total
+		i, next := 0, frames[0].delay
+		for next <= elapsed {
+			i++
+			next += frames[i].delay
+		}
+
+		if files[i] != shown {
+			err := SetFromFileWithEffects(files[i], effects...)
+			if err != nil {
+				return err
+			}
+			shown = files[i]
+		}
+
+		wait := next - elapsed
+		if wait < minInterval {
+			wait = minInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// writeFrames writes every frame as a JPEG named after a hash of its pixels, skipping frames that already exist.
+func writeFrames(frames []animationFrame) ([]string, error) {
+	var files []string
+	for _, frame := range frames {
+		file, err := cacheImage(frame.img)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func decodeAnimation(data []byte) ([]animationFrame, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return decodeGIFAnimation(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return decodeAPNG(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return decodeWebPAnimation(data)
+	default:
+		return nil, errors.New("unsupported animation format")
+	}
+}
+
+// frameDelay follows browsers in showing frames without a usable delay for 100ms.
+func frameDelay(delay time.Duration) time.Duration {
+	if delay <= 10*time.Millisecond {
+		return 100 * time.Millisecond
+	}
+	return delay
+}
+
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	clone := image.NewRGBA(img.Rect)
+	copy(clone.Pix, img.Pix)
+	return clone
+}
+
+func decodeGIFAnimation(data []byte) ([]animationFrame, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	var frames []animationFrame
+	for i, img := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		previous := canvas
+		if disposal == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+
+		draw.Draw(canvas, img.Bounds(), img, img.Bounds().Min, draw.Over)
+		frames = append(frames, animationFrame{cloneRGBA(canvas), frameDelay(time.Duration(g.Delay[i]) * 10 * time.Millisecond)})
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, img.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, nil
+}
+
+// compose draws a decoded frame onto the canvas and applies its disposal afterwards, which works the same for APNG and WebP.
+// disposal is 0 to keep the frame, 1 to clear its area and 2 to restore the previous canvas.
+func compose(canvas *image.RGBA, frame image.Image, offset image.Point, blend bool, disposal int, delay time.Duration) (*image.RGBA, animationFrame) {
+	area := frame.Bounds().Sub(frame.Bounds().Min).Add(offset)
+
+	previous := canvas
+	if disposal == 2 {
+		previous = cloneRGBA(canvas)
+	}
+
+	op := draw.Src
+	if blend {
+		op = draw.Over
+	}
+	draw.Draw(canvas, area, frame, frame.Bounds().Min, op)
+	result := animationFrame{cloneRGBA(canvas), frameDelay(delay)}
+
+	switch disposal {
+	case 1:
+		draw.Draw(canvas, area, image.Transparent, image.Point{}, draw.Src)
+	case 2:
+		canvas = previous
+	}
+	return canvas, result
+}
+
+// decodeAPNG decodes each frame by rebuilding it as a standalone PNG for image/png.
+// A PNG without animation control is returned as a single frame.
+func decodeAPNG(data []byte) ([]animationFrame, error) {
+	var ihdr []byte
+	var header [][]byte
+	type apngFrame struct {
+		control []byte
+		data    [][]byte
+	}
+	var frames []*apngFrame
+	animated, seenData := false, false
+
+	for offset := 8; offset+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		if offset+12+length > len(data) {
+			return nil, errors.New("png chunk out of bounds")
+		}
+		typ, chunk := string(data[offset+4:offset+8]), data[offset+8:offset+8+length]
+		offset += 12 + length
+
+		switch typ {
+		case "IHDR":
+			ihdr = chunk
+		case "acTL":
+			animated = true
+		case "fcTL":
+			if len(chunk) < 26 {
+				return nil, errors.New("invalid apng frame control")
+			}
+			frames = append(frames, &apngFrame{control: chunk})
+		case "IDAT":
+			seenData = true
+			// the default image is only part of the animation if a frame control precedes it
+			if len(frames) > 0 {
+				frames[len(frames)-1].data = append(frames[len(frames)-1].data, chunk)
+			}
+		case "fdAT":
+			seenData = true
+			if len(frames) == 0 || len(chunk) < 4 {
+				return nil, errors.New("invalid apng frame data")
+			}
+			frames[len(frames)-1].data = append(frames[len(frames)-1].data, chunk[4:])
+		case "IEND":
+		default:
+			// palette, transparency and color space chunks apply to every frame
+			if !seenData {
+				header = append(header, data[offset-12-length:offset])
+			}
+		}
+	}
+
+	if !animated || len(frames) == 0 {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return []animationFrame{{toRGBA(img), 0}}, nil
+	}
+	if len(ihdr) != 13 {
+		return nil, errors.New("invalid png header")
+	}
+
+	width, height := binary.BigEndian.Uint32(ihdr), binary.BigEndian.Uint32(ihdr[4:])
+	canvas := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	var result []animationFrame
+	for i, frame := range frames {
+		control := frame.control
+		var buf bytes.Buffer
+		buf.WriteString("\x89PNG\r\n\x1a\n")
+		frameHeader := append(append([]byte{}, control[4:12]...), ihdr[8:]...)
+		writePNGChunk(&buf, "IHDR", frameHeader)
+		for _, chunk := range header {
+			buf.Write(chunk)
+		}
+		for _, chunk := range frame.data {
+			writePNGChunk(&buf, "IDAT", chunk)
+		}
+		writePNGChunk(&buf, "IEND", nil)
+
+		img, err := png.Decode(&buf)
+		if err != nil {
+			return nil, err
+		}
+
+		offset := image.Pt(int(binary.BigEndian.Uint32(control[12:])), int(binary.BigEndian.Uint32(control[16:])))
+		numerator, denominator := binary.BigEndian.Uint16(control[20:]), binary.BigEndian.Uint16(control[22:])
+		if denominator == 0 {
+			denominator = 100
+		}
+		delay := time.Duration(numerator) * time.Second / time.Duration(denominator)
+
+		disposal := int(control[24])
+		if i == 0 && disposal == 2 {
+			// there is no previous canvas to restore for the first frame
+			disposal = 1
+		}
+
+		var composed animationFrame
+		canvas, composed = compose(canvas, img, offset, control[25] == 1, disposal, delay)
+		result = append(result, composed)
+	}
+	return result, nil
+}
+
+func writePNGChunk(buf *bytes.Buffer, typ string, data []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	buf.WriteString(typ)
+	buf.Write(data)
+	binary.Write(buf, binary.BigEndian, crc.Sum32())
+}
+
+// decodeWebPAnimation decodes each ANMF frame by rebuilding it as a standalone WebP for x/image/webp.
+// A WebP without frames is returned as a single frame.
+func decodeWebPAnimation(data []byte) ([]animationFrame, error) {
+	chunks := webpChunks(data[12:])
+	var canvas *image.RGBA
+	var result []animationFrame
+
+	for _, chunk := range chunks {
+		switch chunk.typ {
+		case "VP8X":
+			if len(chunk.data) < 10 {
+				return nil, errors.New("invalid webp header")
+			}
+			canvas = image.NewRGBA(image.Rect(0, 0, int(uint24(chunk.data[4:]))+1, int(uint24(chunk.data[7:]))+1))
+		case "ANMF":
+			if canvas == nil || len(chunk.data) < 16 {
+				return nil, errors.New("invalid webp frame")
+			}
+			frame := chunk.data
+			offset := image.Pt(int(uint24(frame))*2, int(uint24(frame[3:]))*2)
+			width, height := uint24(frame[6:])+1, uint24(frame[9:])+1
+			delay := time.Duration(uint24(frame[12:])) * time.Millisecond
+
+			img, err := webp.Decode(bytes.NewReader(standaloneWebP(frame[16:], width, height)))
+			if err != nil {
+				return nil, err
+			}
+
+			disposal := int(frame[15] & 1)
+			var composed animationFrame
+			canvas, composed = compose(canvas, img, offset, frame[15]&2 == 0, disposal, delay)
+			result = append(result, composed)
+		}
+	}
+
+	if len(result) == 0 {
+		img, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return []animationFrame{{toRGBA(img), 0}}, nil
+	}
+	return result, nil
+}
+
+type webpChunk struct {
+	typ  string
+	data []byte
+}
+
+func webpChunks(data []byte) []webpChunk {
+	var chunks []webpChunk
+	for len(data) >= 8 {
+		length := int(binary.LittleEndian.Uint32(data[4:]))
+		if 8+length > len(data) {
+			break
+		}
+		chunks = append(chunks, webpChunk{string(data[:4]), data[8 : 8+length]})
+		// chunks are padded to an even size
+		next := 8 + length + length%2
+		if next > len(data) {
+			next = len(data)
+		}
+		data = data[next:]
+	}
+	return chunks
+}
+
+// standaloneWebP wraps the ALPH and VP8/VP8L chunks of a frame in a RIFF container.
+func standaloneWebP(frame []byte, width, height uint32) []byte {
+	var body bytes.Buffer
+	alpha := false
+	for _, chunk := range webpChunks(frame) {
+		alpha = alpha || chunk.typ == "ALPH"
+	}
+	if alpha {
+		// a VP8X header is required for a separate alpha chunk
+		header := make([]byte, 10)
+		header[0] = 0x10
+		putUint24(header[4:], width-1)
+		putUint24(header[7:], height-1)
+		writeWebPChunk(&body, "VP8X", header)
+	}
+	for _, chunk := range webpChunks(frame) {
+		if chunk.typ == "ALPH" || chunk.typ == "VP8 " || chunk.typ == "VP8L" {
+			writeWebPChunk(&body, chunk.typ, chunk.data)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+body.Len()))
+	buf.WriteString("WEBP")
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+func writeWebPChunk(buf *bytes.Buffer, typ string, data []byte) {
+	buf.WriteString(typ)
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -0,0 +1,304 @@
+package wallpaper
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+var (
+	frameRed   = color.RGBA{200, 50, 30, 255}
+	frameGreen = color.RGBA{40, 180, 70, 255}
+	frameBlue  = color.RGBA{30, 60, 190, 255}
+	frameClear = color.RGBA{}
+)
+
+// testFrame is a frame of a test animation: a solid rectangle, which is transparent where hole returns true.
+type testFrame struct {
+	rect     image.Rectangle
+	color    color.RGBA
+	hole     func(x, y int) bool
+	delay    int
+	blend    bool
+	disposal int
+}
+
+func (frame testFrame) transparent(x, y int) bool {
+	return frame.hole != nil && frame.hole(x, y)
+}
+
+// topLeft makes the top left pixel of a frame transparent.
+func topLeft(x, y int) bool { return x == 0 && y == 0 }
+
+func everywhere(x, y int) bool { return true }
+
+// wantFrame is the expected canvas after a frame, as the color of a few pixels.
+type wantFrame struct {
+	delay  time.Duration
+	pixels map[image.Point]color.RGBA
+}
+
+func checkFrames(t *testing.T, frames []animationFrame, want []wantFrame) {
+	t.Helper()
+	if len(frames) != len(want) {
+		t.Fatalf("%d frames, want %d", len(frames), len(want))
+	}
+	for i, frame := range frames {
+		if frame.img.Rect != image.Rect(0, 0, 4, 4) {
+			t.Errorf("frame %d is %v", i, frame.img.Rect)
+		}
+		if frame.delay != want[i].delay {
+			t.Errorf("frame %d lasts %v, want %v", i, frame.delay, want[i].delay)
+		}
+		for point, c := range want[i].pixels {
+			if got := frame.img.RGBAAt(point.X, point.Y); got != c {
+				t.Errorf("frame %d: pixel %v is %v, want %v", i, point, got, c)
+			}
+		}
+	}
+}
+
+// pngChunk returns a PNG chunk with its length and CRC.
+func pngChunk(typ string, data []byte) []byte {
+	var buf bytes.Buffer
+	writePNGChunk(&buf, typ, data)
+	return buf.Bytes()
+}
+
+// apngData returns the zlib stream of an 8-bit RGBA image without filters, as stored in IDAT and fdAT.
+func apngData(frame testFrame) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	for y := 0; y < frame.rect.Dy(); y++ {
+		w.Write([]byte{0})
+		for x := 0; x < frame.rect.Dx(); x++ {
+			c := frame.color
+			if frame.transparent(x, y) {
+				c.A = 0
+			}
+			w.Write([]byte{c.R, c.G, c.B, c.A})
+		}
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+// buildAPNG returns a 4x4 APNG of frames, whose delays are in hundredths of a second.
+// With a hidden default image, a frame of a different color comes first that is not part of the animation.
+func buildAPNG(frames []testFrame, hidden bool) []byte {
+	u32 := func(b []byte, v int) []byte { return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v)) }
+	u16 := func(b []byte, v int) []byte { return append(b, byte(v>>8), byte(v)) }
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	buf.Write(pngChunk("IHDR", append(u32(u32(nil, 4), 4), 8, 6, 0, 0, 0)))
+	buf.Write(pngChunk("acTL", u32(u32(nil, len(frames)), 0)))
+	if hidden {
+		buf.Write(pngChunk("IDAT", apngData(testFrame{rect: image.Rect(0, 0, 4, 4), color: frameBlue})))
+	}
+
+	sequence := 0
+	for i, frame := range frames {
+		control := u32(nil, sequence)
+		control = u32(u32(control, frame.rect.Dx()), frame.rect.Dy())
+		control = u32(u32(control, frame.rect.Min.X), frame.rect.Min.Y)
+		control = u16(u16(control, frame.delay), 100)
+		control = append(control, byte(frame.disposal), 0)
+		if frame.blend {
+			control[25] = 1
+		}
+		buf.Write(pngChunk("fcTL", control))
+		sequence++
+
+		if i == 0 && !hidden {
+			buf.Write(pngChunk("IDAT", apngData(frame)))
+			continue
+		}
+		buf.Write(pngChunk("fdAT", append(u32(nil, sequence), apngData(frame)...)))
+		sequence++
+	}
+	buf.Write(pngChunk("IEND", nil))
+	return buf.Bytes()
+}
+
+// apngFrames covers every blend and dispose op. The comments give the canvas after each frame.
+var apngFrames = []testFrame{
+	// red everywhere
+	{rect: image.Rect(0, 0, 4, 4), color: frameRed, delay: 50},
+	// green in the bottom right quarter, which is restored afterwards
+	{rect: image.Rect(2, 2, 4, 4), color: frameGreen, disposal: 2},
+	// blue in the top left quarter except for its red corner, which is cleared afterwards
+	{rect: image.Rect(0, 0, 2, 2), color: frameBlue, hole: topLeft, blend: true, disposal: 1, delay: 25},
+	// a transparent pixel replaces the red top right one
+	{rect: image.Rect(3, 0, 4, 1), color: frameGreen, hole: everywhere},
+}
+
+var apngWant = []wantFrame{
+	{500 * time.Millisecond, map[image.Point]color.RGBA{{0, 0}: frameRed, {3, 3}: frameRed}},
+	{100 * time.Millisecond, map[image.Point]color.RGBA{{0, 0}: frameRed, {1, 2}: frameRed, {2, 2}: frameGreen, {3, 3}: frameGreen}},
+	{250 * time.Millisecond, map[image.Point]color.RGBA{{0, 0}: frameRed, {1, 0}: frameBlue, {1, 1}: frameBlue, {2, 2}: frameRed, {3, 3}: frameRed}},
+	{100 * time.Millisecond, map[image.Point]color.RGBA{{0, 0}: frameClear, {1, 1}: frameClear, {2, 0}: frameRed, {3, 0}: frameClear, {3, 3}: frameRed}},
+}
+
+func TestDecodeAPNG(t *testing.T) {
+	for _, hidden := range []bool{false, true} {
+		frames, err := decodeAnimation(buildAPNG(apngFrames, hidden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFrames(t, frames, apngWant)
+	}
+}
+
+func TestDecodeAPNGFirstFrameRestoresPrevious(t *testing.T) {
+	// with nothing to restore, the first frame is cleared instead
+	frames, err := decodeAnimation(buildAPNG([]testFrame{
+		{rect: image.Rect(0, 0, 4, 4), color: frameRed, delay: 50, disposal: 2},
+		{rect: image.Rect(0, 0, 1, 1), color: frameGreen, delay: 50},
+	}, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFrames(t, frames, []wantFrame{
+		{500 * time.Millisecond, map[image.Point]color.RGBA{{0, 0}: frameRed, {3, 3}: frameRed}},
+		{500 * time.Millisecond, map[image.Point]color.RGBA{{0, 0}: frameGreen, {3, 3}: frameClear}},
+	})
+}
+
+// bitWriter packs bits least significant first, as VP8L expects.
+type bitWriter struct {
+	data  []byte
+	nBits uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	for i := uint(0); i < n; i++ {
+		if w.nBits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(v>>i&1) << (w.nBits % 8)
+		w.nBits++
+	}
+}
+
+// vp8l returns a lossless WebP bitstream of frame.
+// Every channel uses a prefix code of a single symbol, except alpha, whose two symbols take a bit per pixel.
+func vp8l(frame testFrame) []byte {
+	var w bitWriter
+	width, height := frame.rect.Dx(), frame.rect.Dy()
+	w.write(0x2f, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	w.write(1, 1) // alpha is used
+	w.write(0, 3) // version
+	w.write(0, 1) // no transform
+	w.write(0, 1) // no color cache
+	w.write(0, 1) // no meta prefix codes
+
+	single := func(symbol uint8) {
+		w.write(1, 1) // simple code
+		w.write(0, 1) // one symbol
+		w.write(1, 1) // of 8 bits
+		w.write(uint32(symbol), 8)
+	}
+	single(frame.color.G)
+	single(frame.color.R)
+	single(frame.color.B)
+	// alpha: 0 is coded as 0 and 255 as 1
+	w.write(1, 1)
+	w.write(1, 1)
+	w.write(1, 1)
+	w.write(0, 8)
+	w.write(255, 8)
+	single(0) // distance
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			opaque := uint32(1)
+			if frame.transparent(x, y) {
+				opaque = 0
+			}
+			w.write(opaque, 1)
+		}
+	}
+	return w.data
+}
+
+// buildWebP returns a 4x4 animated WebP of frames, whose delays are in milliseconds.
+func buildWebP(frames []testFrame) []byte {
+	var body bytes.Buffer
+	header := make([]byte, 10)
+	header[0] = 0x10 | 0x02 // alpha and animation
+	putUint24(header[4:], 3)
+	putUint24(header[7:], 3)
+	writeWebPChunk(&body, "VP8X", header)
+	writeWebPChunk(&body, "ANIM", make([]byte, 6))
+
+	for _, frame := range frames {
+		control := make([]byte, 16)
+		putUint24(control, uint32(frame.rect.Min.X/2))
+		putUint24(control[3:], uint32(frame.rect.Min.Y/2))
+		putUint24(control[6:], uint32(frame.rect.Dx()-1))
+		putUint24(control[9:], uint32(frame.rect.Dy()-1))
+		putUint24(control[12:], uint32(frame.delay))
+		if !frame.blend {
+			control[15] |= 2
+		}
+		control[15] |= byte(frame.disposal)
+
+		var data bytes.Buffer
+		data.Write(control)
+		writeWebPChunk(&data, "VP8L", vp8l(frame))
+		writeWebPChunk(&body, "ANMF", data.Bytes())
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+body.Len()))
+	buf.WriteString("WEBP")
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+func TestDecodeWebPAnimation(t *testing.T) {
+	frames, err := decodeAnimation(buildWebP([]testFrame{
+		// red everywhere
+		{rect: image.Rect(0, 0, 4, 4), color: frameRed, delay: 500},
+		// green in the bottom right quarter, which is cleared afterwards
+		{rect: image.Rect(2, 2, 4, 4), color: frameGreen, disposal: 1},
+		// blue in the top left quarter except for its red corner
+		{rect: image.Rect(0, 0, 2, 2), color: frameBlue, hole: topLeft, blend: true, delay: 250},
+		// transparent pixels replace the top right quarter
+		{rect: image.Rect(2, 0, 4, 2), color: frameGreen, hole: everywhere, delay: 250},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFrames(t, frames, []wantFrame{
+		{500 * time.Millisecond, map[image.Point]color.RGBA{{0, 0}: frameRed, {3, 3}: frameRed}},
+		{100 * time.Millisecond, map[image.Point]color.RGBA{{0, 0}: frameRed, {1, 2}: frameRed, {2, 2}: frameGreen, {3, 3}: frameGreen}},
+		{250 * time.Millisecond, map[image.Point]color.RGBA{{0, 0}: frameRed, {1, 0}: frameBlue, {1, 1}: frameBlue, {2, 2}: frameClear, {3, 3}: frameClear, {3, 0}: frameRed}},
+		{250 * time.Millisecond, map[image.Point]color.RGBA{{0, 0}: frameRed, {1, 1}: frameBlue, {2, 0}: frameClear, {3, 1}: frameClear, {0, 3}: frameRed}},
+	})
+}
+
+func TestDecodeAnimationErrors(t *testing.T) {
+	apng := buildAPNG(apngFrames, false)
+	webp := buildWebP(apngFrames[:1])
+	tests := map[string][]byte{
+		"unknown format":      []byte("BM not an animation"),
+		"truncated png chunk": apng[:len(apng)-20],
+		"short frame control": bytes.Replace(apng, pngChunk("acTL", []byte{0, 0, 0, 4, 0, 0, 0, 0}), append(pngChunk("acTL", []byte{0, 0, 0, 4, 0, 0, 0, 0}), pngChunk("fcTL", []byte{0})...), 1),
+		"webp frame first":    append(append([]byte(nil), webp[:12]...), webp[12+8+10+8+6:]...),
+	}
+	for name, data := range tests {
+		_, err := decodeAnimation(data)
+		if err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
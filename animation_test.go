@@ -0,0 +1,53 @@
+package wallpaper_test
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ktkv419/wallpaper"
+)
+
+func TestPlayAnimationMinInterval(t *testing.T) {
+	desktop := useFake(t, "", wallpaper.Crop)
+
+	// three frames of 10ms, which browsers show for 100ms each
+	palette := color.Palette{color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{0, 0, 255, 255}}
+	animation := &gif.GIF{}
+	for i := range palette {
+		frame := image.NewPaletted(image.Rect(0, 0, 16, 16), palette)
+		for j := range frame.Pix {
+			frame.Pix[j] = uint8(i)
+		}
+		animation.Image = append(animation.Image, frame)
+		animation.Delay = append(animation.Delay, 1)
+	}
+	file := filepath.Join(t.TempDir(), "animation.gif")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gif.EncodeAll(f, animation)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no interval still changes the wallpaper at most once a second
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	err = wallpaper.PlayAnimation(ctx, file, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := desktop.History()
+	if len(history) != 2 {
+		t.Errorf("%d changes in 1.5s, want 2", len(history))
+	}
+}
@@ -0,0 +1,38 @@
+package wallpaper
+
+// Backend is implemented by anything that can get and set the wallpaper,
+// so that code using the library can be tested against a fake such as the fakedesktop package.
+type Backend interface {
+	Get() (string, error)
+	SetFromFile(file string) error
+	SetMode(mode Mode) error
+	// ModeMatches reports whether the desktop shows the wallpaper with mode.
+	// Desktops without a distinct setting for every mode report true for all modes sharing one.
+	ModeMatches(mode Mode) (bool, error)
+}
+
+// System is the Backend of the running desktop, which calls Get, SetFromFile and SetMode.
+var System Backend = systemBackend{}
+
+// Default is the Backend used by Apply, GetResolved, SetFromURL, SetFromFileWithEffects, SetFromGenerator,
+// PlayAnimation and the Run functions. Tests can replace it with a fake such as the fakedesktop package.
+// The dark variant of a Manifest and RunVideo always use the running desktop.
+var Default = System
+
+type systemBackend struct{}
+
+func (systemBackend) Get() (string, error) {
+	return Get()
+}
+
+func (systemBackend) SetFromFile(file string) error {
+	return SetFromFile(file)
+}
+
+func (systemBackend) SetMode(mode Mode) error {
+	return SetMode(mode)
+}
+
+func (systemBackend) ModeMatches(mode Mode) (bool, error) {
+	return modeMatches(mode)
+}
@@ -0,0 +1,182 @@
+package wallpaper_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ktkv419/wallpaper"
+	"github.com/ktkv419/wallpaper/fakedesktop"
+)
+
+// useFake makes the library use a fake desktop and a temporary cache for the rest of the test.
+func useFake(t *testing.T, image string, mode wallpaper.Mode) *fakedesktop.Desktop {
+	desktop := fakedesktop.New(image, mode)
+	wallpaper.Default = desktop
+	wallpaper.CacheDir = t.TempDir()
+	t.Cleanup(func() {
+		wallpaper.Default = wallpaper.System
+		wallpaper.CacheDir = ""
+	})
+	return desktop
+}
+
+func writeImage(t *testing.T, name string) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	err = png.Encode(file, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestApplyWithFakeDesktop(t *testing.T) {
+	desktop := useFake(t, "/srv/old.jpg", wallpaper.Crop)
+	file := writeImage(t, filepath.Join(t.TempDir(), "new.png"))
+	fit := wallpaper.Fit
+	manifest := wallpaper.Manifest{Image: file, Mode: &fit}
+
+	result, err := wallpaper.Apply(manifest)
+	if err != nil || result.ExitCode() != 2 {
+		t.Fatalf("first Apply = %v, %v", result, err)
+	}
+	if image, _ := desktop.Get(); image != file || desktop.Mode() != wallpaper.Fit {
+		t.Errorf("desktop shows %q with mode %d", image, desktop.Mode())
+	}
+
+	result, err = wallpaper.Apply(manifest)
+	if err != nil || result.ExitCode() != 0 {
+		t.Errorf("second Apply = %v, %v", result, err)
+	}
+	if len(desktop.History()) != 2 {
+		t.Errorf("history = %v, want one image and one mode change", desktop.History())
+	}
+
+	desktop.Fail(fakedesktop.SetMode, errors.New("settings daemon not running"))
+	tile := wallpaper.Tile
+	result, err = wallpaper.Apply(wallpaper.Manifest{Image: file, Mode: &tile})
+	if err == nil || result.ExitCode() != 1 {
+		t.Errorf("Apply with a failing SetMode = %v, %v", result, err)
+	}
+}
+
+func TestSetFromGeneratorWithFakeDesktop(t *testing.T) {
+	desktop := useFake(t, "", wallpaper.Crop)
+
+	err := wallpaper.SetFromGenerator(wallpaper.Solid{Color: color.RGBA{20, 40, 60, 255}}, wallpaper.Brightness(0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image, _ := desktop.Get()
+	if _, err := os.Stat(image); err != nil {
+		t.Errorf("desktop shows %q: %v", image, err)
+	}
+}
+
+func TestSetFromURLWithFakeDesktop(t *testing.T) {
+	desktop := useFake(t, "/srv/old.jpg", wallpaper.Crop)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	err := wallpaper.SetFromURL(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image, _ := desktop.Get()
+	if data, err := os.ReadFile(image); err != nil || string(data) != "image" {
+		t.Errorf("desktop shows %q: %q, %v", image, data, err)
+	}
+}
+
+func TestRunDayNightMapRotates(t *testing.T) {
+	desktop := useFake(t, "", wallpaper.Crop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	start := time.Now()
+	go func() {
+		// no interval is raised to a second
+		done <- wallpaper.RunDayNightMap(ctx, wallpaper.DayNightMap{}, 0)
+	}()
+	for len(desktop.History()) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Errorf("3 changes after %v", elapsed)
+	}
+	cancel()
+	err := <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range desktop.History() {
+		if !strings.HasPrefix(filepath.Base(change.Image), "wallpaper-worldmap-") {
+			t.Errorf("map rendered to %s", change.Image)
+		}
+	}
+}
+
+func TestRunOverlayRotates(t *testing.T) {
+	desktop := useFake(t, "", wallpaper.Crop)
+	file := writeImage(t, filepath.Join(t.TempDir(), "background.png"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	start := time.Now()
+	go func() {
+		// no interval is raised to a second
+		done <- wallpaper.RunOverlay(ctx, file, wallpaper.Overlay{{Template: "{{.Date.UnixNano}}"}}, 0)
+	}()
+	for len(desktop.History()) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Errorf("3 changes after %v", elapsed)
+	}
+	cancel()
+	err := <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]bool{}
+	history := desktop.History()
+	for i, change := range history {
+		files[change.Image] = true
+		if i > 0 && change.Image == history[i-1].Image {
+			t.Errorf("change %d sets %s again", i, change.Image)
+		}
+	}
+	if len(files) != 2 {
+		t.Errorf("%d changes used %d files, want 2", len(history), len(files))
+	}
+	for file := range files {
+		if !strings.HasPrefix(filepath.Base(file), "wallpaper-overlay-") {
+			t.Errorf("overlay rendered to %s", file)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package wallpaper
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// Layout is the arrangement of images in a Collage.
+type Layout int
+
+const (
+	// Grid crops every image to an equally sized cell.
+	Grid Layout = iota
+	// Masonry keeps aspect ratios and stacks images in columns.
+	Masonry
+	// Polaroid scatters framed, slightly rotated images.
+	Polaroid
+)
+
+// Collage lays out several images on a single wallpaper.
+type Collage struct {
+	Files  []string
+	Layout Layout
+	// Gutter is the space between and around the images of the Grid and Masonry layouts, in pixels.
+	Gutter     int
+	Background color.RGBA
+	// Seed controls the placement and rotation of the Polaroid layout.
+	Seed int64
+}
+
+// Generate implements Generator.
+func (collage Collage) Generate(width, height int) (image.Image, error) {
+	if collage.Layout < Grid || collage.Layout > Polaroid {
+		return nil, fmt.Errorf("invalid collage layout %d", collage.Layout)
+	}
+	if collage.Gutter < 0 {
+		return nil, errors.New("negative collage gutter")
+	}
+
+	var images []image.Image
+	for _, file := range collage.Files {
+		img, err := decodeImage(file)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(collage.Background), image.Point{}, draw.Src)
+	if len(images) == 0 {
+		return canvas, nil
+	}
+
+	var err error
+	switch collage.Layout {
+	case Grid:
+		err = collage.drawGrid(canvas, images)
+	case Masonry:
+		err = collage.drawMasonry(canvas, images)
+	case Polaroid:
+		collage.drawPolaroid(canvas, images)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return canvas, nil
+}
+
+// columns returns how many columns make the cells closest to the canvas aspect ratio.
+func columns(canvas image.Rectangle, n int) int {
+	cols := int(math.Ceil(math.Sqrt(float64(n) * float64(canvas.Dx()) / float64(canvas.Dy()))))
+	if cols > n {
+		cols = n
+	}
+	return cols
+}
+
+// errGutterTooWide is returned when the gutters leave no room for the images.
+var errGutterTooWide = errors.New("collage gutter leaves no room for the images")
+
+func (collage Collage) drawGrid(canvas *image.RGBA, images []image.Image) error {
+	bounds := canvas.Bounds()
+	cols := columns(bounds, len(images))
+	rows := (len(images) + cols - 1) / cols
+	gutter := collage.Gutter
+	cellWidth := (bounds.Dx() - gutter*(cols+1)) / cols
+	cellHeight := (bounds.Dy() - gutter*(rows+1)) / rows
+	if cellWidth < 1 || cellHeight < 1 {
+		return errGutterTooWide
+	}
+
+	for i, img := range images {
+		x := gutter + (i%cols)*(cellWidth+gutter)
+		y := gutter + (i/cols)*(cellHeight+gutter)
+		cell := image.Rect(x, y, x+cellWidth, y+cellHeight)
+		xdraw.CatmullRom.Scale(canvas, cell, img, smartCrop(img, cellWidth, cellHeight), draw.Src, nil)
+	}
+	return nil
+}
+
+func (collage Collage) drawMasonry(canvas *image.RGBA, images []image.Image) error {
+	bounds := canvas.Bounds()
+	cols := columns(bounds, len(images))
+	gutter := collage.Gutter
+	columnWidth := (bounds.Dx() - gutter*(cols+1)) / cols
+	if columnWidth < 1 {
+		return errGutterTooWide
+	}
+	heights := make([]int, cols)
+	for i := range heights {
+		heights[i] = gutter
+	}
+
+	for _, img := range images {
+		// place each image in the shortest column
+		col := 0
+		for i := range heights {
+			if heights[i] < heights[col] {
+				col = i
+			}
+		}
+
+		size := img.Bounds().Size()
+		imgHeight := columnWidth * size.Y / size.X
+		x := gutter + col*(columnWidth+gutter)
+		xdraw.CatmullRom.Scale(canvas, image.Rect(x, heights[col], x+columnWidth, heights[col]+imgHeight), img, img.Bounds(), draw.Src, nil)
+		heights[col] += imgHeight + gutter
+	}
+	return nil
+}
+
+func (collage Collage) drawPolaroid(canvas *image.RGBA, images []image.Image) {
+	bounds := canvas.Bounds()
+	rng := rand.New(rand.NewSource(collage.Seed))
+	photoHeight := bounds.Dy() / 3
+	border := photoHeight / 20
+
+	for _, img := range images {
+		size := img.Bounds().Size()
+		photoWidth := photoHeight * size.X / size.Y
+
+		// white frame with a thicker bottom edge
+		frame := image.NewRGBA(image.Rect(0, 0, photoWidth+2*border, photoHeight+5*border))
+		draw.Draw(frame, frame.Bounds(), image.White, image.Point{}, draw.Src)
+		xdraw.CatmullRom.Scale(frame, image.Rect(border, border, border+photoWidth, border+photoHeight), img, img.Bounds(), draw.Src, nil)
+
+		// keep the frame center far enough from the edges for most of it to be visible
+		fw, fh := float64(frame.Bounds().Dx()), float64(frame.Bounds().Dy())
+		angle := (rng.Float64() - 0.5) * math.Pi / 6
+		cx := float64(bounds.Min.X) + fw/2 + rng.Float64()*math.Max(0, float64(bounds.Dx())-fw)
+		cy := float64(bounds.Min.Y) + fh/2 + rng.Float64()*math.Max(0, float64(bounds.Dy())-fh)
+
+		// rotate around the frame center, then move it to (cx, cy)
+		sin, cos := math.Sincos(angle)
+		transform := f64.Aff3{
+			cos, -sin, cx - cos*fw/2 + sin*fh/2,
+			sin, cos, cy - sin*fw/2 - cos*fh/2,
+		}
+		xdraw.ApproxBiLinear.Transform(canvas, transform, frame, frame.Bounds(), draw.Over, nil)
+	}
+}
+
+// smartCrop returns the region of img with the aspect ratio width:height that contains the most edges.
+func smartCrop(img image.Image, width, height int) image.Rectangle {
+	bounds := img.Bounds()
+	cropWidth, cropHeight := bounds.Dx(), bounds.Dy()
+	if cropWidth*height > cropHeight*width {
+		cropWidth = cropHeight * width / height
+	} else {
+		cropHeight = cropWidth * height / width
+	}
+
+	// measure edges on a small grayscale copy
+	const samples = 64
+	small := image.NewGray(image.Rect(0, 0, samples, samples))
+	xdraw.ApproxBiLinear.Scale(small, small.Bounds(), img, bounds, draw.Src, nil)
+	var energy [samples][samples]float64
+	for y := 1; y < samples; y++ {
+		for x := 1; x < samples; x++ {
+			v := float64(small.GrayAt(x, y).Y)
+			energy[y][x] = math.Abs(v-float64(small.GrayAt(x-1, y).Y)) + math.Abs(v-float64(small.GrayAt(x, y-1).Y))
+		}
+	}
+
+	// slide a window along whichever axis has room to spare
+	windowWidth := cropWidth * samples / bounds.Dx()
+	windowHeight := cropHeight * samples / bounds.Dy()
+	var best image.Point
+	bestEnergy := -1.0
+	for wy := 0; wy <= samples-windowHeight; wy++ {
+		for wx := 0; wx <= samples-windowWidth; wx++ {
+			var sum float64
+			for y := wy; y < wy+windowHeight; y++ {
+				for x := wx; x < wx+windowWidth; x++ {
+					sum += energy[y][x]
+				}
+			}
+			if sum > bestEnergy {
+				best, bestEnergy = image.Pt(wx, wy), sum
+			}
+		}
+	}
+
+	min := image.Pt(
+		bounds.Min.X+best.X*bounds.Dx()/samples,
+		bounds.Min.Y+best.Y*bounds.Dy()/samples,
+	)
+	crop := image.Rect(min.X, min.Y, min.X+cropWidth, min.Y+cropHeight)
+	// the window is quantized to samples, so keep the crop inside the image
+	return crop.Sub(image.Pt(
+		clampInt(crop.Max.X-bounds.Max.X, 0, crop.Dx()),
+		clampInt(crop.Max.Y-bounds.Max.Y, 0, crop.Dy()),
+	))
+}
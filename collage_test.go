@@ -0,0 +1,155 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	collageBackground = color.RGBA{10, 20, 30, 255}
+	collageColors     = []color.RGBA{
+		{200, 40, 40, 255},
+		{40, 200, 40, 255},
+		{40, 40, 200, 255},
+		{200, 200, 40, 255},
+	}
+)
+
+// collageFiles writes a solid image of the given size for each of colors.
+func collageFiles(t *testing.T, width, height int, colors []color.RGBA) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var files []string
+	for i, c := range colors {
+		file := filepath.Join(dir, fmt.Sprintf("%d.png", i))
+		writePNG(t, file, width, height, c)
+		files = append(files, file)
+	}
+	return files
+}
+
+func generateCollage(t *testing.T, collage Collage, width, height int) *image.RGBA {
+	t.Helper()
+	img, err := collage.Generate(width, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toRGBA(img)
+}
+
+func TestCollageGrid(t *testing.T) {
+	files := collageFiles(t, 40, 30, collageColors)
+	img := generateCollage(t, Collage{Files: files, Layout: Grid, Gutter: 10, Background: collageBackground}, 100, 100)
+
+	// two columns and rows of 35x35 cells starting at 10 and 55
+	tests := map[image.Point]color.RGBA{
+		image.Pt(27, 27): collageColors[0],
+		image.Pt(72, 27): collageColors[1],
+		image.Pt(27, 72): collageColors[2],
+		image.Pt(72, 72): collageColors[3],
+		image.Pt(5, 5):   collageBackground,
+		image.Pt(50, 27): collageBackground,
+		image.Pt(27, 50): collageBackground,
+	}
+	for point, want := range tests {
+		if c := img.RGBAAt(point.X, point.Y); !nearRGBA(c, want) {
+			t.Errorf("%v at %v, want %v", c, point, want)
+		}
+	}
+}
+
+func TestCollageMasonry(t *testing.T) {
+	files := collageFiles(t, 20, 10, collageColors[:3])
+	img := generateCollage(t, Collage{Files: files, Layout: Masonry, Gutter: 10, Background: collageBackground}, 100, 100)
+
+	// two 35 pixel columns of 17 pixel tall images; the third goes under the first
+	tests := map[image.Point]color.RGBA{
+		image.Pt(27, 18): collageColors[0],
+		image.Pt(72, 18): collageColors[1],
+		image.Pt(27, 45): collageColors[2],
+		image.Pt(72, 45): collageBackground,
+		image.Pt(50, 18): collageBackground,
+		image.Pt(27, 32): collageBackground,
+	}
+	for point, want := range tests {
+		if c := img.RGBAAt(point.X, point.Y); !nearRGBA(c, want) {
+			t.Errorf("%v at %v, want %v", c, point, want)
+		}
+	}
+}
+
+func TestCollagePolaroid(t *testing.T) {
+	files := collageFiles(t, 40, 30, collageColors)
+	render := func(seed int64) *image.RGBA {
+		return generateCollage(t, Collage{Files: files, Layout: Polaroid, Background: collageBackground, Seed: seed}, 300, 200)
+	}
+
+	first := render(1)
+	if string(first.Pix) != string(render(1).Pix) {
+		t.Error("the same seed rendered different collages")
+	}
+	if string(first.Pix) == string(render(2).Pix) {
+		t.Error("different seeds rendered the same collage")
+	}
+
+	found := map[color.RGBA]bool{}
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			found[first.RGBAAt(x, y)] = true
+		}
+	}
+	for _, want := range append([]color.RGBA{{255, 255, 255, 255}}, collageColors...) {
+		if !found[want] {
+			t.Errorf("no %v pixels in the collage", want)
+		}
+	}
+}
+
+func TestCollageErrors(t *testing.T) {
+	files := collageFiles(t, 40, 30, collageColors)
+	tests := map[string]Collage{
+		"gutter fills the grid":    {Files: files, Layout: Grid, Gutter: 33},
+		"gutter fills the columns": {Files: files, Layout: Masonry, Gutter: 50},
+		"negative gutter":          {Files: files, Layout: Grid, Gutter: -1},
+		"unknown layout":           {Files: files, Layout: Polaroid + 1},
+		"missing file":             {Files: []string{filepath.Join(t.TempDir(), "missing.png")}},
+	}
+	for name, collage := range tests {
+		_, err := collage.Generate(99, 99)
+		if err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestCollageEmpty(t *testing.T) {
+	img := generateCollage(t, Collage{Background: collageBackground}, 8, 8)
+	if c := img.RGBAAt(4, 4); c != collageBackground {
+		t.Errorf("empty collage is %v, want the background", c)
+	}
+}
+
+func TestSmartCrop(t *testing.T) {
+	// a flat image with a checkerboard on the right
+	img := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.RGBA{128, 128, 128, 255}
+			if x >= 200 && (x/10+y/10)%2 == 0 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	crop := smartCrop(img, 50, 50)
+	if crop.Dx() != 100 || crop.Dy() != 100 {
+		t.Errorf("crop is %v, want 100x100", crop.Size())
+	}
+	if crop.Min.X < 190 || !crop.In(img.Bounds()) {
+		t.Errorf("crop = %v, want the right edge", crop)
+	}
+}
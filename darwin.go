@@ -3,6 +3,8 @@
 package wallpaper
 
 import (
+	"fmt"
+	"image"
 	"os/exec"
 	"os/user"
 	"path/filepath"
@@ -31,7 +33,40 @@ func SetMode(mode Mode) error {
 	return nil
 }
 
+// modeMatches always reports true since SetMode does nothing on macOS.
+func modeMatches(mode Mode) (bool, error) {
+	return true, nil
+}
+
+// Monitors returns ErrUnsupportedDE since monitor geometry is only available on Linux.
+func Monitors() ([]image.Rectangle, error) {
+	return nil, ErrUnsupportedDE
+}
+
+// getDark returns ErrUnsupportedDE since there is no separate dark wallpaper.
+func getDark() (string, error) {
+	return "", ErrUnsupportedDE
+}
+
+// setDark returns ErrUnsupportedDE since there is no separate dark wallpaper.
+func setDark(file string) error {
+	return ErrUnsupportedDE
+}
+
+// getLockScreen returns ErrUnsupportedDE since the lock screen shows the desktop picture.
+func getLockScreen() (string, error) {
+	return "", ErrUnsupportedDE
+}
+
+// setLockScreen returns ErrUnsupportedDE since the lock screen shows the desktop picture.
+func setLockScreen(file string) error {
+	return ErrUnsupportedDE
+}
+
 func getCacheDir() (string, error) {
+	if CacheDir != "" {
+		return CacheDir, nil
+	}
 	usr, err := user.Current()
 	if err != nil {
 		return "", err
@@ -39,3 +74,15 @@ func getCacheDir() (string, error) {
 
 	return filepath.Join(usr.HomeDir, "Library", "Caches"), nil
 }
+
+// getScreenSize returns the size of the main display in points.
+func getScreenSize() (int, int, error) {
+	stdout, err := exec.Command("osascript", "-e", `tell application "Finder" to get bounds of window of desktop`).Output()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var left, top, right, bottom int
+	_, err = fmt.Sscanf(strings.TrimSpace(string(stdout)), "%d, %d, %d, %d", &left, &top, &right, &bottom)
+	return right - left, bottom - top, err
+}
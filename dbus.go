@@ -0,0 +1,652 @@
+package wallpaper
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// dbusConn is a minimal D-Bus client, just enough to follow MPRIS media players.
+// Messages are sent in little-endian byte order.
+type dbusConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	serial uint32
+	// name is the unique name assigned by the bus.
+	name string
+	// pending holds the messages that arrived while call waited for a reply.
+	pending []*dbusMessage
+}
+
+const (
+	dbusMethodCall   = 1
+	dbusMethodReturn = 2
+	dbusErrorReply   = 3
+	dbusSignal       = 4
+)
+
+// dbusMessage is a message with its decoded body.
+// Values are decoded to the Go type of the same size, strings, object paths and signatures to string,
+// arrays, structs and dict entries to []interface{} and variants to dbusVariant.
+type dbusMessage struct {
+	typ         byte
+	serial      uint32
+	path        string
+	iface       string
+	member      string
+	errorName   string
+	replySerial uint32
+	destination string
+	sender      string
+	signature   string
+	body        []interface{}
+}
+
+type dbusVariant struct {
+	signature string
+	value     interface{}
+}
+
+// dbusError is an error returned by a method call.
+type dbusError struct {
+	name    string
+	message string
+}
+
+func (err dbusError) Error() string {
+	return err.name + ": " + err.message
+}
+
+var errDBusTruncated = errors.New("d-bus message truncated")
+
+// dialSessionBus connects to the bus named by $DBUS_SESSION_BUS_ADDRESS.
+func dialSessionBus() (*dbusConn, error) {
+	address := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	if address == "" {
+		return nil, errors.New("no d-bus session bus")
+	}
+
+	// the address may list alternatives, which are tried in order
+	var conn net.Conn
+	var err error
+	for _, alternative := range strings.Split(address, ";") {
+		conn, err = dialDBusAddress(alternative)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &dbusConn{conn: conn, reader: bufio.NewReader(conn)}
+	err = c.auth()
+	if err == nil {
+		var reply []interface{}
+		reply, err = c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", "")
+		if err == nil && len(reply) == 1 {
+			c.name, _ = reply[0].(string)
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// dialDBusAddress connects to a unix:path= or unix:abstract= address.
+func dialDBusAddress(address string) (net.Conn, error) {
+	i := strings.IndexByte(address, ':')
+	if i == -1 || address[:i] != "unix" {
+		return nil, fmt.Errorf("unsupported d-bus address %q", address)
+	}
+
+	for _, param := range strings.Split(address[i+1:], ",") {
+		key, value := param, ""
+		if j := strings.IndexByte(param, '='); j != -1 {
+			key, value = param[:j], param[j+1:]
+		}
+		value, err := url.PathUnescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid d-bus address %q", address)
+		}
+
+		switch key {
+		case "path":
+			return net.Dial("unix", value)
+		case "abstract":
+			return net.Dial("unix", "@"+value)
+		}
+	}
+	return nil, fmt.Errorf("unsupported d-bus address %q", address)
+}
+
+// auth authenticates as the current user, whom the bus knows from the socket credentials.
+func (c *dbusConn) auth() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	_, err := io.WriteString(c.conn, "\x00AUTH EXTERNAL "+uid+"\r\n")
+	if err != nil {
+		return err
+	}
+
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("d-bus authentication failed: %s", strings.TrimSpace(line))
+	}
+	_, err = io.WriteString(c.conn, "BEGIN\r\n")
+	return err
+}
+
+func (c *dbusConn) close() error {
+	return c.conn.Close()
+}
+
+// send writes msg with the next serial number, which it returns.
+func (c *dbusConn) send(msg *dbusMessage) (uint32, error) {
+	types, err := splitSignature(msg.signature)
+	if err != nil {
+		return 0, err
+	}
+	if len(types) != len(msg.body) {
+		return 0, fmt.Errorf("d-bus signature %q doesn't match %d values", msg.signature, len(msg.body))
+	}
+	var body dbusEncoder
+	for i, typ := range types {
+		err = body.value(typ, msg.body[i])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	c.serial++
+	var fields []interface{}
+	field := func(code byte, signature string, value interface{}) {
+		fields = append(fields, []interface{}{code, dbusVariant{signature, value}})
+	}
+	for _, f := range []struct {
+		code      byte
+		signature string
+		value     string
+	}{{1, "o", msg.path}, {2, "s", msg.iface}, {3, "s", msg.member}, {4, "s", msg.errorName}, {6, "s", msg.destination}, {8, "g", msg.signature}} {
+		if f.value != "" {
+			field(f.code, f.signature, f.value)
+		}
+	}
+	if msg.replySerial != 0 {
+		field(5, "u", msg.replySerial)
+	}
+
+	header := dbusEncoder{buf: []byte{'l', msg.typ, 0, 1}}
+	header.u32(uint32(len(body.buf)))
+	header.u32(c.serial)
+	err = header.value("a(yv)", fields)
+	if err != nil {
+		return 0, err
+	}
+	header.align(8)
+
+	_, err = c.conn.Write(append(header.buf, body.buf...))
+	return c.serial, err
+}
+
+// read reads the next message from the bus.
+func (c *dbusConn) read() (*dbusMessage, error) {
+	fixed := make([]byte, 16)
+	_, err := io.ReadFull(c.reader, fixed)
+	if err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("invalid d-bus byte order")
+	}
+	bodyLength, fieldsLength := order.Uint32(fixed[4:]), order.Uint32(fixed[12:])
+	// the specification limits messages to 128 MiB
+	if bodyLength > 1<<27 || fieldsLength > 1<<27 {
+		return nil, errors.New("d-bus message too large")
+	}
+	headerLength := (16 + int(fieldsLength) + 7) / 8 * 8
+	data := make([]byte, headerLength+int(bodyLength))
+	copy(data, fixed)
+	_, err = io.ReadFull(c.reader, data[16:])
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &dbusMessage{typ: fixed[1], serial: order.Uint32(fixed[8:])}
+	header := dbusDecoder{data: data[:headerLength], pos: 12, order: order}
+	fields, err := header.value("a(yv)")
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields.([]interface{}) {
+		field := field.([]interface{})
+		value := field[1].(dbusVariant).value
+		switch field[0].(byte) {
+		case 1:
+			msg.path, _ = value.(string)
+		case 2:
+			msg.iface, _ = value.(string)
+		case 3:
+			msg.member, _ = value.(string)
+		case 4:
+			msg.errorName, _ = value.(string)
+		case 5:
+			msg.replySerial, _ = value.(uint32)
+		case 6:
+			msg.destination, _ = value.(string)
+		case 7:
+			msg.sender, _ = value.(string)
+		case 8:
+			msg.signature, _ = value.(string)
+		}
+	}
+
+	types, err := splitSignature(msg.signature)
+	if err != nil {
+		return nil, err
+	}
+	// the body starts at a multiple of 8, so alignment can be counted from its start
+	body := dbusDecoder{data: data[headerLength:], order: order}
+	for _, typ := range types {
+		value, err := body.value(typ)
+		if err != nil {
+			return nil, err
+		}
+		msg.body = append(msg.body, value)
+	}
+	return msg, nil
+}
+
+// call invokes a method and waits for its reply. Other messages that arrive in the meantime are kept for next.
+func (c *dbusConn) call(destination, path, iface, member, signature string, args ...interface{}) ([]interface{}, error) {
+	serial, err := c.send(&dbusMessage{
+		typ:         dbusMethodCall,
+		destination: destination,
+		path:        path,
+		iface:       iface,
+		member:      member,
+		signature:   signature,
+		body:        args,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		if msg.replySerial != serial || (msg.typ != dbusMethodReturn && msg.typ != dbusErrorReply) {
+			c.pending = append(c.pending, msg)
+			continue
+		}
+
+		if msg.typ == dbusErrorReply {
+			err := dbusError{name: msg.errorName}
+			if len(msg.body) > 0 {
+				err.message, _ = msg.body[0].(string)
+			}
+			return nil, err
+		}
+		return msg.body, nil
+	}
+}
+
+// next returns the next message that isn't a reply to call.
+func (c *dbusConn) next() (*dbusMessage, error) {
+	if len(c.pending) > 0 {
+		msg := c.pending[0]
+		c.pending = c.pending[1:]
+		return msg, nil
+	}
+	return c.read()
+}
+
+// splitSignature splits a signature into its complete types.
+func splitSignature(signature string) ([]string, error) {
+	var types []string
+	for signature != "" {
+		n, err := typeLength(signature)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, signature[:n])
+		signature = signature[n:]
+	}
+	return types, nil
+}
+
+// typeLength returns the length of the first complete type of signature.
+func typeLength(signature string) (int, error) {
+	if signature == "" {
+		return 0, errors.New("d-bus signature ends early")
+	}
+
+	switch signature[0] {
+	case 'a':
+		n, err := typeLength(signature[1:])
+		return 1 + n, err
+	case '(', '{':
+		end := byte(')')
+		if signature[0] == '{' {
+			end = '}'
+		}
+		i := 1
+		for i < len(signature) && signature[i] != end {
+			n, err := typeLength(signature[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+		if i == len(signature) {
+			return 0, fmt.Errorf("invalid d-bus signature %q", signature)
+		}
+		return i + 1, nil
+	}
+	if strings.IndexByte("ybnqiuxtdsogvh", signature[0]) == -1 {
+		return 0, fmt.Errorf("invalid d-bus signature %q", signature)
+	}
+	return 1, nil
+}
+
+// dbusAlignment returns the alignment of a complete type.
+func dbusAlignment(typ string) int {
+	switch typ[0] {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 'h', 's', 'o', 'a':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 1
+}
+
+type dbusEncoder struct {
+	buf []byte
+}
+
+func (e *dbusEncoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *dbusEncoder) u32(v uint32) {
+	e.align(4)
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(e.buf[len(e.buf)-4:], v)
+}
+
+// value encodes v, which has the complete type typ and the Go type it is decoded to.
+func (e *dbusEncoder) value(typ string, v interface{}) error {
+	mismatch := fmt.Errorf("d-bus value %v doesn't match type %s", v, typ)
+	var ok bool
+	switch typ[0] {
+	case 'y':
+		var b byte
+		b, ok = v.(byte)
+		e.buf = append(e.buf, b)
+	case 'b':
+		var b bool
+		b, ok = v.(bool)
+		if b {
+			e.u32(1)
+		} else {
+			e.u32(0)
+		}
+	case 'n', 'q':
+		var n uint16
+		switch v := v.(type) {
+		case int16:
+			n, ok = uint16(v), typ == "n"
+		case uint16:
+			n, ok = v, typ == "q"
+		}
+		e.align(2)
+		e.buf = append(e.buf, byte(n), byte(n>>8))
+	case 'i', 'u', 'h':
+		var n uint32
+		switch v := v.(type) {
+		case int32:
+			n, ok = uint32(v), typ == "i"
+		case uint32:
+			n, ok = v, typ != "i"
+		}
+		e.u32(n)
+	case 'x', 't', 'd':
+		var n uint64
+		switch v := v.(type) {
+		case int64:
+			n, ok = uint64(v), typ == "x"
+		case uint64:
+			n, ok = v, typ == "t"
+		case float64:
+			n, ok = math.Float64bits(v), typ == "d"
+		}
+		e.align(8)
+		e.buf = append(e.buf, make([]byte, 8)...)
+		binary.LittleEndian.PutUint64(e.buf[len(e.buf)-8:], n)
+	case 's', 'o':
+		var s string
+		s, ok = v.(string)
+		e.u32(uint32(len(s)))
+		e.buf = append(append(e.buf, s...), 0)
+	case 'g':
+		var s string
+		s, ok = v.(string)
+		e.buf = append(append(append(e.buf, byte(len(s))), s...), 0)
+	case 'v':
+		var variant dbusVariant
+		variant, ok = v.(dbusVariant)
+		if !ok {
+			break
+		}
+		err := e.value("g", variant.signature)
+		if err == nil {
+			err = e.value(variant.signature, variant.value)
+		}
+		return err
+	case 'a':
+		var items []interface{}
+		items, ok = v.([]interface{})
+		if !ok {
+			break
+		}
+		e.u32(0)
+		lengthAt := len(e.buf) - 4
+		// the padding to the first element doesn't count towards the length
+		e.align(dbusAlignment(typ[1:]))
+		start := len(e.buf)
+		for _, item := range items {
+			err := e.value(typ[1:], item)
+			if err != nil {
+				return err
+			}
+		}
+		binary.LittleEndian.PutUint32(e.buf[lengthAt:], uint32(len(e.buf)-start))
+	case '(', '{':
+		var fields []interface{}
+		fields, ok = v.([]interface{})
+		types, err := splitSignature(typ[1 : len(typ)-1])
+		if err != nil {
+			return err
+		}
+		if !ok || len(fields) != len(types) {
+			return mismatch
+		}
+		e.align(8)
+		for i, field := range fields {
+			err := e.value(types[i], field)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if !ok {
+		return mismatch
+	}
+	return nil
+}
+
+// dbusDecoder reads values from data, which starts at a multiple of 8 bytes from the start of the message.
+type dbusDecoder struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+// take returns the next n bytes after aligning to align.
+func (d *dbusDecoder) take(align, n int) ([]byte, error) {
+	pos := (d.pos + align - 1) / align * align
+	if n < 0 || pos+n > len(d.data) || pos+n < pos {
+		return nil, errDBusTruncated
+	}
+	d.pos = pos + n
+	return d.data[pos : pos+n], nil
+}
+
+func (d *dbusDecoder) value(typ string) (interface{}, error) {
+	switch typ[0] {
+	case 'y':
+		b, err := d.take(1, 1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		b, err := d.take(4, 4)
+		if err != nil {
+			return nil, err
+		}
+		return d.order.Uint32(b) != 0, nil
+	case 'n', 'q':
+		b, err := d.take(2, 2)
+		if err != nil {
+			return nil, err
+		}
+		if typ == "n" {
+			return int16(d.order.Uint16(b)), nil
+		}
+		return d.order.Uint16(b), nil
+	case 'i', 'u', 'h':
+		b, err := d.take(4, 4)
+		if err != nil {
+			return nil, err
+		}
+		if typ == "i" {
+			return int32(d.order.Uint32(b)), nil
+		}
+		return d.order.Uint32(b), nil
+	case 'x', 't', 'd':
+		b, err := d.take(8, 8)
+		if err != nil {
+			return nil, err
+		}
+		n := d.order.Uint64(b)
+		switch typ {
+		case "x":
+			return int64(n), nil
+		case "d":
+			return math.Float64frombits(n), nil
+		}
+		return n, nil
+	case 's', 'o':
+		b, err := d.take(4, 4)
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.take(1, int(d.order.Uint32(b))+1)
+		if err != nil {
+			return nil, err
+		}
+		return string(s[:len(s)-1]), nil
+	case 'g':
+		b, err := d.take(1, 1)
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.take(1, int(b[0])+1)
+		if err != nil {
+			return nil, err
+		}
+		return string(s[:len(s)-1]), nil
+	case 'v':
+		signature, err := d.value("g")
+		if err != nil {
+			return nil, err
+		}
+		types, err := splitSignature(signature.(string))
+		if err != nil {
+			return nil, err
+		}
+		if len(types) != 1 {
+			return nil, fmt.Errorf("invalid d-bus variant signature %q", signature)
+		}
+		value, err := d.value(types[0])
+		return dbusVariant{types[0], value}, err
+	case 'a':
+		b, err := d.take(4, 4)
+		if err != nil {
+			return nil, err
+		}
+		_, err = d.take(dbusAlignment(typ[1:]), 0)
+		if err != nil {
+			return nil, err
+		}
+		end := d.pos + int(d.order.Uint32(b))
+		if end > len(d.data) || end < d.pos {
+			return nil, errDBusTruncated
+		}
+		items := []interface{}{}
+		for d.pos < end {
+			item, err := d.value(typ[1:])
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case '(', '{':
+		_, err := d.take(8, 0)
+		if err != nil {
+			return nil, err
+		}
+		types, err := splitSignature(typ[1 : len(typ)-1])
+		if err != nil {
+			return nil, err
+		}
+		var fields []interface{}
+		for _, t := range types {
+			field, err := d.value(t)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, field)
+		}
+		return fields, nil
+	}
+	return nil, fmt.Errorf("invalid d-bus signature %q", typ)
+}
@@ -0,0 +1,301 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDBusRoundTrip(t *testing.T) {
+	tests := []struct {
+		signature string
+		values    []interface{}
+	}{
+		{"y", []interface{}{byte(7)}},
+		// every value after the byte needs padding
+		{"yb", []interface{}{byte(1), true}},
+		{"yn", []interface{}{byte(1), int16(-2)}},
+		{"yq", []interface{}{byte(1), uint16(65535)}},
+		{"yi", []interface{}{byte(1), int32(-3)}},
+		{"yu", []interface{}{byte(1), uint32(4)}},
+		{"yx", []interface{}{byte(1), int64(math.MinInt64)}},
+		{"yt", []interface{}{byte(1), uint64(math.MaxUint64)}},
+		{"yd", []interface{}{byte(1), 0.5}},
+		{"ysog", []interface{}{byte(1), "café", "/org/mpris/MediaPlayer2", "a{sv}"}},
+		{"yv", []interface{}{byte(1), dbusVariant{"as", []interface{}{"a", "b"}}}},
+		{"yat", []interface{}{byte(1), []interface{}{uint64(1), uint64(2)}}},
+		{"yat", []interface{}{byte(1), []interface{}{}}},
+		{"y(ybs)", []interface{}{byte(1), []interface{}{byte(2), false, "x"}}},
+		{"a{sv}", []interface{}{[]interface{}{
+			[]interface{}{"mpris:length", dbusVariant{"x", int64(1000)}},
+			[]interface{}{"xesam:artist", dbusVariant{"as", []interface{}{"Artist"}}},
+		}}},
+	}
+	for _, test := range tests {
+		types, err := splitSignature(test.signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e dbusEncoder
+		for i, typ := range types {
+			err = e.value(typ, test.values[i])
+			if err != nil {
+				t.Fatalf("%s: %v", test.signature, err)
+			}
+		}
+
+		d := dbusDecoder{data: e.buf, order: binary.LittleEndian}
+		var got []interface{}
+		for _, typ := range types {
+			value, err := d.value(typ)
+			if err != nil {
+				t.Fatalf("%s: %v", test.signature, err)
+			}
+			got = append(got, value)
+		}
+		if !reflect.DeepEqual(got, test.values) || d.pos != len(e.buf) {
+			t.Errorf("%s: decoded %#v from %x, want %#v", test.signature, got, e.buf, test.values)
+		}
+
+		// every truncation is an error rather than a panic
+		for n := 0; n < len(e.buf); n++ {
+			d := dbusDecoder{data: e.buf[:n], order: binary.LittleEndian}
+			var err error
+			for _, typ := range types {
+				if _, err = d.value(typ); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				t.Errorf("%s: decoded %d of %d bytes", test.signature, n, len(e.buf))
+			}
+		}
+	}
+}
+
+func TestDBusEncodeMismatch(t *testing.T) {
+	tests := map[string]interface{}{
+		"s":     1,
+		"i":     uint32(1),
+		"as":    []string{"a"},
+		"(ss)":  []interface{}{"a"},
+		"v":     "a",
+		"a{sv}": []interface{}{[]interface{}{"key", "not a variant"}},
+	}
+	for typ, value := range tests {
+		var e dbusEncoder
+		if err := e.value(typ, value); err == nil {
+			t.Errorf("encoded %#v as %s", value, typ)
+		}
+	}
+}
+
+func TestSplitSignature(t *testing.T) {
+	types, err := splitSignature("sa{sv}as(ii)v")
+	if err != nil || !reflect.DeepEqual(types, []string{"s", "a{sv}", "as", "(ii)", "v"}) {
+		t.Errorf("splitSignature = %q, %v", types, err)
+	}
+	for _, signature := range []string{"a", "(ii", "{sv", "z", "a{sv"} {
+		if _, err := splitSignature(signature); err == nil {
+			t.Errorf("splitSignature(%q) accepted", signature)
+		}
+	}
+}
+
+// startBus runs a private dbus-daemon and points DBUS_SESSION_BUS_ADDRESS at it for the rest of the test.
+// The test is skipped if dbus-daemon isn't installed.
+func startBus(t *testing.T) {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "bus.conf")
+	err = os.WriteFile(config, []byte(`<busconfig>
+  <type>session</type>
+  <listen>unix:path=`+filepath.Join(dir, "bus")+`</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+config, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(stdout).ReadString('\n')
+		address <- strings.TrimSpace(line)
+	}()
+	select {
+	case line := <-address:
+		if line == "" {
+			t.Fatal("dbus-daemon exited without an address")
+		}
+		setEnv(t, "DBUS_SESSION_BUS_ADDRESS", line)
+	case <-time.After(10 * time.Second):
+		t.Fatal("dbus-daemon didn't start")
+	}
+}
+
+func TestDialSessionBus(t *testing.T) {
+	startBus(t)
+	c, err := dialSessionBus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+
+	reply, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "ListNames", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := reply[0].([]interface{})
+	found := false
+	for _, name := range names {
+		found = found || name == c.name
+	}
+	if !strings.HasPrefix(c.name, ":") || !found {
+		t.Errorf("own name %q not in %v", c.name, names)
+	}
+
+	_, err = c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "GetNameOwner", "s", "org.mpris.MediaPlayer2.missing")
+	if err, ok := err.(dbusError); !ok || err.name != "org.freedesktop.DBus.Error.NameHasNoOwner" {
+		t.Errorf("GetNameOwner of a missing name = %v", err)
+	}
+
+	setEnv(t, "DBUS_SESSION_BUS_ADDRESS", "tcp:host=localhost,port=1")
+	if _, err := dialSessionBus(); err == nil {
+		t.Error("dialed a tcp address")
+	}
+}
+
+// fakePlayer is an MPRIS media player on the test bus.
+type fakePlayer struct {
+	conn *dbusConn
+	// mu serializes writes to conn, which both the test and serve send on
+	mu         sync.Mutex
+	properties []interface{}
+	done       chan struct{}
+}
+
+// startPlayer connects a player owning org.mpris.MediaPlayer2.name until the test ends or quit is called.
+func startPlayer(t *testing.T, name, status, artURL, artist, title string) *fakePlayer {
+	t.Helper()
+	c, err := dialSessionBus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakePlayer{conn: c, properties: mprisProperties(status, artURL, artist, title), done: make(chan struct{})}
+	// DBUS_NAME_FLAG_DO_NOT_QUEUE
+	reply, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "RequestName", "su", mprisPrefix+name, uint32(4))
+	if err != nil || reply[0] != uint32(1) {
+		t.Fatalf("RequestName = %v, %v", reply, err)
+	}
+
+	go p.serve()
+	t.Cleanup(p.quit)
+	return p
+}
+
+func mprisProperties(status, artURL, artist, title string) []interface{} {
+	var artists []interface{}
+	if artist != "" {
+		artists = append(artists, artist)
+	}
+	metadata := []interface{}{
+		[]interface{}{"mpris:trackid", dbusVariant{"o", "/org/mpris/MediaPlayer2/Track/1"}},
+		[]interface{}{"mpris:length", dbusVariant{"x", int64(180000000)}},
+		[]interface{}{"mpris:artUrl", dbusVariant{"s", artURL}},
+		[]interface{}{"xesam:artist", dbusVariant{"as", artists}},
+		[]interface{}{"xesam:title", dbusVariant{"s", title}},
+	}
+	return []interface{}{
+		[]interface{}{"PlaybackStatus", dbusVariant{"s", status}},
+		[]interface{}{"Volume", dbusVariant{"d", 1.0}},
+		[]interface{}{"Metadata", dbusVariant{"a{sv}", metadata}},
+	}
+}
+
+// serve answers GetAll for the player interface until the connection closes.
+func (p *fakePlayer) serve() {
+	defer close(p.done)
+	for {
+		msg, err := p.conn.next()
+		if err != nil {
+			return
+		}
+		if msg.typ != dbusMethodCall {
+			continue
+		}
+
+		p.mu.Lock()
+		reply := &dbusMessage{typ: dbusMethodReturn, replySerial: msg.serial, destination: msg.sender}
+		if msg.member == "GetAll" && msg.path == mprisPath && len(msg.body) == 1 && msg.body[0] == mprisPlayerInterface {
+			reply.signature, reply.body = "a{sv}", []interface{}{p.properties}
+		} else {
+			reply.typ, reply.errorName = dbusErrorReply, "org.freedesktop.DBus.Error.UnknownMethod"
+			reply.signature, reply.body = "s", []interface{}{fmt.Sprintf("%s.%s not implemented", msg.iface, msg.member)}
+		}
+		p.conn.send(reply)
+		p.mu.Unlock()
+	}
+}
+
+// set changes the state of the player and announces it with PropertiesChanged.
+func (p *fakePlayer) set(t *testing.T, status, artURL, artist, title string) {
+	t.Helper()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.properties = mprisProperties(status, artURL, artist, title)
+	_, err := p.conn.send(&dbusMessage{
+		typ:       dbusSignal,
+		path:      mprisPath,
+		iface:     "org.freedesktop.DBus.Properties",
+		member:    "PropertiesChanged",
+		signature: "sa{sv}as",
+		body:      []interface{}{mprisPlayerInterface, p.properties, []interface{}{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// quit disconnects the player, which releases its name.
+func (p *fakePlayer) quit() {
+	p.conn.close()
+	<-p.done
+}
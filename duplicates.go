@@ -0,0 +1,246 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"  // register decoder
+	_ "image/jpeg" // register decoder
+	_ "image/png"  // register decoder
+	"math"
+	"math/bits"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ImageHash is a perceptual hash algorithm used by Duplicates.
+type ImageHash int
+
+const (
+	// DHash compares the brightness of neighboring cells. It is fast and tolerates resizing and re-encoding.
+	DHash ImageHash = iota
+	// PHash compares the lowest frequencies of a discrete cosine transform. It also tolerates small brightness and contrast changes.
+	PHash
+)
+
+// DuplicateHash is the hash algorithm Duplicates uses.
+var DuplicateHash = DHash
+
+// DuplicateThreshold is the maximum number of differing hash bits for two images to be considered duplicates.
+var DuplicateThreshold = 10
+
+// DecodeError is returned by Duplicates for files it skipped because they couldn't be decoded.
+type DecodeError struct {
+	// Errs maps every skipped file to its decoding error.
+	Errs map[string]error
+}
+
+func (err *DecodeError) Error() string {
+	var files []string
+	for file := range err.Errs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for i, file := range files {
+		files[i] = fmt.Sprintf("%s: %v", file, err.Errs[file])
+	}
+	return "could not decode images: " + strings.Join(files, "; ")
+}
+
+// Hash returns the 64-bit perceptual hash of img.
+func (hash ImageHash) Hash(img image.Image) uint64 {
+	switch hash {
+	case DHash:
+		return dHash(img)
+	case PHash:
+		return pHash(img)
+	default:
+		panic("invalid image hash")
+	}
+}
+
+type hashedImage struct {
+	file   string
+	hash   uint64
+	pixels int
+}
+
+// Duplicates groups near-identical images using DuplicateHash.
+// Each group is sorted by resolution, highest first. Files without duplicates are not reported.
+// Files that can't be decoded are skipped and listed in a *DecodeError, which is returned along with the groups of the others.
+func Duplicates(files []string) ([][]string, error) {
+	var images []hashedImage
+	skipped := &DecodeError{Errs: map[string]error{}}
+	for _, file := range files {
+		img, err := decodeImage(file)
+		if err != nil {
+			skipped.Errs[file] = err
+			continue
+		}
+
+		size := img.Bounds().Size()
+		images = append(images, hashedImage{file, DuplicateHash.Hash(img), size.X * size.Y})
+	}
+
+	// union-find over every pair within the threshold
+	parent := make([]int, len(images))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range images {
+		for j := i + 1; j < len(images); j++ {
+			if bits.OnesCount64(images[i].hash^images[j].hash) <= DuplicateThreshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := make(map[int][]hashedImage)
+	var roots []int
+	for i := range images {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], images[i])
+	}
+
+	var duplicates [][]string
+	for _, root := range roots {
+		group := groups[root]
+		if len(group) < 2 {
+			continue
+		}
+
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].pixels > group[j].pixels
+		})
+		var files []string
+		for _, img := range group {
+			files = append(files, img.file)
+		}
+		duplicates = append(duplicates, files)
+	}
+
+	if len(skipped.Errs) > 0 {
+		return duplicates, skipped
+	}
+	return duplicates, nil
+}
+
+func decodeImage(file string) (image.Image, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// dHash shrinks the image to 9x8 grayscale cells and records whether each cell is brighter than its right neighbor.
+func dHash(img image.Image) uint64 {
+	var cells [8][9]float64
+	bounds := img.Bounds()
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 9; x++ {
+			cells[y][x] = meanLuminance(img, image.Rect(
+				bounds.Min.X+x*bounds.Dx()/9,
+				bounds.Min.Y+y*bounds.Dy()/8,
+				bounds.Min.X+(x+1)*bounds.Dx()/9,
+				bounds.Min.Y+(y+1)*bounds.Dy()/8,
+			))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// pHash shrinks the image to 32x32 grayscale cells and records whether each of the 8x8 lowest DCT frequencies is above their median.
+func pHash(img image.Image) uint64 {
+	var cells [32][32]float64
+	bounds := img.Bounds()
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			cells[y][x] = meanLuminance(img, image.Rect(
+				bounds.Min.X+x*bounds.Dx()/32,
+				bounds.Min.Y+y*bounds.Dy()/32,
+				bounds.Min.X+(x+1)*bounds.Dx()/32,
+				bounds.Min.Y+(y+1)*bounds.Dy()/32,
+			))
+		}
+	}
+
+	// the DCT-II is separable, and only its 8 lowest frequencies are needed in each direction
+	var cosines [8][32]float64
+	for u := 0; u < 8; u++ {
+		for x := 0; x < 32; x++ {
+			cosines[u][x] = math.Cos(float64((2*x+1)*u) * math.Pi / 64)
+		}
+	}
+	var rows [32][8]float64
+	for y := 0; y < 32; y++ {
+		for u := 0; u < 8; u++ {
+			for x := 0; x < 32; x++ {
+				rows[y][u] += cells[y][x] * cosines[u][x]
+			}
+		}
+	}
+	var coefficients [64]float64
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			for y := 0; y < 32; y++ {
+				coefficients[v*8+u] += rows[y][u] * cosines[v][y]
+			}
+		}
+	}
+
+	// the DC coefficient is the mean brightness, which would skew the median
+	sorted := append([]float64(nil), coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for _, coefficient := range coefficients {
+		hash <<= 1
+		if coefficient > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// meanLuminance returns the average Rec. 601 luma of rect in the range [0, 1].
+func meanLuminance(img image.Image, rect image.Rectangle) float64 {
+	var sum float64
+	var n int
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0xffff
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
@@ -0,0 +1,118 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// scene draws a sky gradient with a sun and a hill; flipped mirrors it.
+func scene(width, height int, flipped bool) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{uint8(40 + 100*y/height + 80*x/width), uint8(120 + 100*y/height), 230, 255}
+			dx, dy := x-width*3/4, y-height/4
+			if dx*dx+dy*dy < width*width/64 {
+				c = color.RGBA{250, 220, 80, 255}
+			}
+			if y > height*2/3+(x-width/2)*(x-width/2)/width/2 {
+				c = color.RGBA{40, 110, 40, 255}
+			}
+			if flipped {
+				img.Set(width-1-x, y, c)
+			} else {
+				img.Set(x, y, c)
+			}
+		}
+	}
+	return img
+}
+
+func writeScene(t *testing.T, name string, img image.Image) string {
+	t.Helper()
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if filepath.Ext(name) == ".jpg" {
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: 60})
+	} else {
+		err = png.Encode(f, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	original := scene(320, 240, false)
+	small := image.NewRGBA(image.Rect(0, 0, 160, 120))
+	xdraw.CatmullRom.Scale(small, small.Rect, original, original.Rect, draw.Src, nil)
+
+	// the re-encoded copy comes first to check that groups are sorted by resolution
+	reencoded := writeScene(t, filepath.Join(dir, "reencoded.jpg"), original)
+	resized := writeScene(t, filepath.Join(dir, "resized.jpg"), small)
+	large := writeScene(t, filepath.Join(dir, "large.png"), scene(640, 480, false))
+	mirrored := writeScene(t, filepath.Join(dir, "mirrored.png"), scene(320, 240, true))
+	files := []string{reencoded, resized, mirrored, large}
+
+	for _, hash := range []ImageHash{DHash, PHash} {
+		DuplicateHash = hash
+		groups, err := Duplicates(files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := [][]string{{large, reencoded, resized}}
+		if !reflect.DeepEqual(groups, want) {
+			t.Errorf("hash %d: Duplicates = %v, want %v", hash, groups, want)
+		}
+	}
+	DuplicateHash = DHash
+}
+
+func TestDuplicatesSkipsUndecodable(t *testing.T) {
+	dir := t.TempDir()
+	first := writeScene(t, filepath.Join(dir, "first.png"), scene(64, 48, false))
+	second := writeScene(t, filepath.Join(dir, "second.png"), scene(64, 48, false))
+	broken := filepath.Join(dir, "broken.jpg")
+	err := os.WriteFile(broken, []byte("not an image"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := Duplicates([]string{first, broken, second})
+	decodeErr, ok := err.(*DecodeError)
+	if !ok || len(decodeErr.Errs) != 1 || decodeErr.Errs[broken] == nil {
+		t.Fatalf("error = %v, want a DecodeError for %s", err, broken)
+	}
+	if !reflect.DeepEqual(groups, [][]string{{first, second}}) {
+		t.Errorf("Duplicates = %v", groups)
+	}
+}
+
+func TestImageHashDistance(t *testing.T) {
+	original := scene(320, 240, false)
+	mirrored := scene(320, 240, true)
+	for _, hash := range []ImageHash{DHash, PHash} {
+		if hash.Hash(original) != hash.Hash(scene(320, 240, false)) {
+			t.Errorf("hash %d isn't deterministic", hash)
+		}
+		if distance := bits.OnesCount64(hash.Hash(original) ^ hash.Hash(mirrored)); distance <= DuplicateThreshold {
+			t.Errorf("hash %d: mirrored image is %d bits away", hash, distance)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package wallpaper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CacheMaxAge is how long a rendered wallpaper is kept in the cache directory after it was last used.
+// The current wallpaper is never removed.
+var CacheMaxAge = 30 * 24 * time.Hour
+
+// Effect transforms an image before it is set as the wallpaper.
+type Effect interface {
+	Apply(img *image.RGBA) *image.RGBA
+}
+
+// Blur applies a Gaussian blur with the given standard deviation in pixels.
+type Blur float64
+
+// Brightness scales every channel by the given factor. 1 leaves the image unchanged.
+type Brightness float64
+
+// Contrast scales the distance of every channel from mid-gray by the given factor. 1 leaves the image unchanged.
+type Contrast float64
+
+// Desaturate blends the image towards grayscale. 0 leaves the image unchanged, 1 is fully gray.
+type Desaturate float64
+
+// Tint blends the image towards Color by Amount, which ranges from 0 to 1.
+type Tint struct {
+	Color  color.RGBA
+	Amount float64
+}
+
+// Vignette darkens the edges of the image. 0 leaves the image unchanged, 1 makes the corners black.
+type Vignette float64
+
+// ColorTemperature shifts the white point of the image to the given temperature in kelvin. 6500 leaves the image unchanged.
+type ColorTemperature float64
+
+// Pixelate replaces each block of the given size in pixels with its average color.
+type Pixelate int
+
+// SetFromFileWithEffects applies effects to the image, caches the result and calls SetFromFile.
+// Without effects, it is equivalent to SetFromFile.
+func SetFromFileWithEffects(file string, effects ...Effect) error {
+	if len(effects) == 0 {
+		return Default.SetFromFile(file)
+	}
+
+	rendered, err := renderEffects(file, effects)
+	if err != nil {
+		return err
+	}
+
+	return Default.SetFromFile(rendered)
+}
+
+// renderEffects renders the effect chain once and caches it by the source contents and the effect chain.
+func renderEffects(file string, effects []Effect) (string, error) {
+	source, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write(source)
+	for _, effect := range effects {
+		fmt.Fprintf(hash, "%#v\n", effect)
+	}
+
+	cacheDir, err := getCacheDir()
+	if err != nil {
+		return "", err
+	}
+	rendered := filepath.Join(cacheDir, "wallpaper-"+hex.EncodeToString(hash.Sum(nil))[:16]+".jpg")
+	if _, err := os.Stat(rendered); err == nil {
+		return rendered, touchCached(rendered)
+	}
+
+	img, err := applyEffects(source, effects)
+	if err != nil {
+		return "", err
+	}
+
+	err = writeJPEG(rendered, img)
+	if err != nil {
+		return "", err
+	}
+	pruneCache(cacheDir)
+	return rendered, nil
+}
+
+// touchCached marks a cached file as used, so that pruneCache keeps it.
+func touchCached(file string) error {
+	now := time.Now()
+	return os.Chtimes(file, now, now)
+}
+
+// pruneCache removes the rendered wallpapers in cacheDir that weren't used for CacheMaxAge.
+// It is best effort, and removes nothing if the current wallpaper can't be determined.
+func pruneCache(cacheDir string) {
+	current, err := Default.Get()
+	if err != nil {
+		return
+	}
+	current = removeProtocol(current)
+
+	files, _ := filepath.Glob(filepath.Join(cacheDir, "wallpaper-*.jpg"))
+	for _, file := range files {
+		// rendered wallpapers are named after 16 hex digits of their hash
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "wallpaper-"), ".jpg")
+		if _, err := hex.DecodeString(name); err != nil || len(name) != 16 || file == current {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err == nil && time.Since(info.ModTime()) > CacheMaxAge {
+			os.Remove(file)
+		}
+	}
+}
+
+func applyEffects(source []byte, effects []Effect) (*image.RGBA, error) {
+	img, _, err := image.Decode(bytes.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+
+	rgba := toRGBA(img)
+	for _, effect := range effects {
+		rgba = effect.Apply(rgba)
+	}
+	return rgba, nil
+}
+
+// setRotating writes img to one of two files named after name, picked by the parity of i, and sets it.
+// Loops that render a new image every time use it instead of the cache,
+// so that desktops see a new path on every change without the cache directory growing.
+func setRotating(name string, i int, img image.Image) error {
+	cacheDir, err := getCacheDir()
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Join(cacheDir, fmt.Sprintf("wallpaper-%s-%d.jpg", name, i%2))
+	err = writeJPEG(file, img)
+	if err != nil {
+		return err
+	}
+
+	return Default.SetFromFile(file)
+}
+
+// cacheImage writes img to the cache directory under a name derived from its pixels, unless it is already there.
+func cacheImage(img *image.RGBA) (string, error) {
+	cacheDir, err := getCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	binary.Write(hash, binary.LittleEndian, [2]int32{int32(img.Rect.Dx()), int32(img.Rect.Dy())})
+	hash.Write(img.Pix)
+	file := filepath.Join(cacheDir, "wallpaper-"+hex.EncodeToString(hash.Sum(nil))[:16]+".jpg")
+	if _, err := os.Stat(file); err == nil {
+		return file, touchCached(file)
+	}
+
+	err = writeJPEG(file, img)
+	if err != nil {
+		return "", err
+	}
+	pruneCache(cacheDir)
+	return file, nil
+}
+
+// writeJPEG writes to a temporary file first so that a desktop never reads a partial image.
+func writeJPEG(name string, img image.Image) error {
+	file, err := os.CreateTemp(filepath.Dir(name), ".wallpaper-*.jpg")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	err = jpeg.Encode(file, img, &jpeg.Options{Quality: 95})
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), name)
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// centerCrop returns the largest centered part of bounds with the aspect ratio of width x height.
+func centerCrop(bounds image.Rectangle, width, height int) image.Rectangle {
+	crop := bounds
+	if bounds.Dx()*height > bounds.Dy()*width {
+		w := bounds.Dy() * width / height
+		crop.Min.X += (bounds.Dx() - w) / 2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := bounds.Dx() * height / width
+		crop.Min.Y += (bounds.Dy() - h) / 2
+		crop.Max.Y = crop.Min.Y + h
+	}
+	return crop
+}
+
+// mapPixels calls fn for every pixel with channels in the range [0, 1] and stores the clamped result.
+func mapPixels(img *image.RGBA, fn func(x, y int, r, g, b float64) (float64, float64, float64)) *image.RGBA {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			pix := img.Pix[i : i+3 : i+3]
+			r, g, b := fn(x, y, float64(pix[0])/255, float64(pix[1])/255, float64(pix[2])/255)
+			pix[0], pix[1], pix[2] = clampChannel(r), clampChannel(g), clampChannel(b)
+		}
+	}
+	return img
+}
+
+func clampChannel(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+}
+
+// Apply implements Effect.
+func (blur Blur) Apply(img *image.RGBA) *image.RGBA {
+	sigma := float64(blur)
+	if sigma <= 0 {
+		return img
+	}
+
+	radius := int(math.Ceil(sigma * 3))
+	kernel := make([]float64, 2*radius+1)
+	var sum float64
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	// the kernel is separable, so blur horizontally and then vertically
+	return convolve(convolve(img, kernel, 1, 0), kernel, 0, 1)
+}
+
+// convolve applies a one-dimensional kernel along (dx, dy), clamping at the edges.
+func convolve(img *image.RGBA, kernel []float64, dx, dy int) *image.RGBA {
+	bounds := img.Bounds()
+	out := image.NewRGBA(bounds)
+	radius := len(kernel) / 2
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var acc [4]float64
+			for k, weight := range kernel {
+				sx := clampInt(x+(k-radius)*dx, bounds.Min.X, bounds.Max.X-1)
+				sy := clampInt(y+(k-radius)*dy, bounds.Min.Y, bounds.Max.Y-1)
+				i := img.PixOffset(sx, sy)
+				for c := range acc {
+					acc[c] += float64(img.Pix[i+c]) * weight
+				}
+			}
+			i := out.PixOffset(x, y)
+			for c := range acc {
+				out.Pix[i+c] = uint8(math.Round(math.Max(0, math.Min(255, acc[c]))))
+			}
+		}
+	}
+	return out
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// Apply implements Effect.
+func (brightness Brightness) Apply(img *image.RGBA) *image.RGBA {
+	k := float64(brightness)
+	return mapPixels(img, func(_, _ int, r, g, b float64) (float64, float64, float64) {
+		return r * k, g * k, b * k
+	})
+}
+
+// Apply implements Effect.
+func (contrast Contrast) Apply(img *image.RGBA) *image.RGBA {
+	k := float64(contrast)
+	return mapPixels(img, func(_, _ int, r, g, b float64) (float64, float64, float64) {
+		return (r-0.5)*k + 0.5, (g-0.5)*k + 0.5, (b-0.5)*k + 0.5
+	})
+}
+
+// Apply implements Effect.
+func (desaturate Desaturate) Apply(img *image.RGBA) *image.RGBA {
+	amount := float64(desaturate)
+	return mapPixels(img, func(_, _ int, r, g, b float64) (float64, float64, float64) {
+		gray := 0.299*r + 0.587*g + 0.114*b
+		return lerp(r, gray, amount), lerp(g, gray, amount), lerp(b, gray, amount)
+	})
+}
+
+// Apply implements Effect.
+func (tint Tint) Apply(img *image.RGBA) *image.RGBA {
+	tr, tg, tb := float64(tint.Color.R)/255, float64(tint.Color.G)/255, float64(tint.Color.B)/255
+	return mapPixels(img, func(_, _ int, r, g, b float64) (float64, float64, float64) {
+		return lerp(r, tr, tint.Amount), lerp(g, tg, tint.Amount), lerp(b, tb, tint.Amount)
+	})
+}
+
+// Apply implements Effect.
+func (vignette Vignette) Apply(img *image.RGBA) *image.RGBA {
+	bounds := img.Bounds()
+	cx, cy := float64(bounds.Min.X+bounds.Max.X)/2, float64(bounds.Min.Y+bounds.Max.Y)/2
+	maxDist := math.Hypot(float64(bounds.Dx())/2, float64(bounds.Dy())/2)
+	return mapPixels(img, func(x, y int, r, g, b float64) (float64, float64, float64) {
+		d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) / maxDist
+		k := 1 - float64(vignette)*d*d
+		return r * k, g * k, b * k
+	})
+}
+
+// Apply implements Effect.
+func (pixelate Pixelate) Apply(img *image.RGBA) *image.RGBA {
+	size := int(pixelate)
+	if size <= 1 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	for by := bounds.Min.Y; by < bounds.Max.Y; by += size {
+		for bx := bounds.Min.X; bx < bounds.Max.X; bx += size {
+			block := image.Rect(bx, by, bx+size, by+size).Intersect(bounds)
+
+			var sum [4]int
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					i := img.PixOffset(x, y)
+					for c := range sum {
+						sum[c] += int(img.Pix[i+c])
+					}
+				}
+			}
+
+			n := block.Dx() * block.Dy()
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					i := img.PixOffset(x, y)
+					for c := range sum {
+						img.Pix[i+c] = uint8(sum[c] / n)
+					}
+				}
+			}
+		}
+	}
+	return img
+}
+
+// Apply implements Effect.
+func (temperature ColorTemperature) Apply(img *image.RGBA) *image.RGBA {
+	r0, g0, b0 := kelvinToRGB(6500)
+	r1, g1, b1 := kelvinToRGB(float64(temperature))
+	kr, kg, kb := r1/r0, g1/g0, b1/b0
+	return mapPixels(img, func(_, _ int, r, g, b float64) (float64, float64, float64) {
+		return r * kr, g * kg, b * kb
+	})
+}
+
+// kelvinToRGB approximates the color of a black body at the given temperature, with channels in the range [0, 1].
+// https://tannerhelland.com/2012/09/18/convert-temperature-rgb-algorithm-code.html
+func kelvinToRGB(kelvin float64) (float64, float64, float64) {
+	t := kelvin / 100
+
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+
+	clamp := func(v float64) float64 {
+		return math.Max(0, math.Min(255, v)) / 255
+	}
+	return clamp(r), clamp(g), clamp(b)
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
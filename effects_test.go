@@ -0,0 +1,189 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pixels returns an image with one row of the given colors.
+func pixels(colors ...color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(colors), 1))
+	for x, c := range colors {
+		img.SetRGBA(x, 0, c)
+	}
+	return img
+}
+
+func TestEffects(t *testing.T) {
+	tests := []struct {
+		name   string
+		effect Effect
+		in     []color.RGBA
+		want   []color.RGBA
+	}{
+		{"Brightness", Brightness(0.5), []color.RGBA{{200, 100, 50, 255}}, []color.RGBA{{100, 50, 25, 255}}},
+		{"Brightness clamps", Brightness(2), []color.RGBA{{200, 100, 50, 255}}, []color.RGBA{{255, 200, 100, 255}}},
+		{"Contrast", Contrast(3), []color.RGBA{{50, 100, 150, 255}, {200, 0, 255, 255}}, []color.RGBA{{0, 45, 195, 255}, {255, 0, 255, 255}}},
+		{"Contrast 1", Contrast(1), []color.RGBA{{50, 100, 150, 255}}, []color.RGBA{{50, 100, 150, 255}}},
+		{"Desaturate", Desaturate(1), []color.RGBA{{255, 0, 0, 255}, {0, 0, 255, 255}}, []color.RGBA{{76, 76, 76, 255}, {29, 29, 29, 255}}},
+		{"Desaturate 0", Desaturate(0), []color.RGBA{{255, 0, 0, 255}}, []color.RGBA{{255, 0, 0, 255}}},
+		{"Tint", Tint{color.RGBA{0, 0, 255, 255}, 0.25}, []color.RGBA{{200, 100, 0, 255}}, []color.RGBA{{150, 75, 64, 255}}},
+		{"Pixelate", Pixelate(2), []color.RGBA{{0, 0, 0, 255}, {100, 200, 50, 255}, {7, 7, 7, 255}}, []color.RGBA{{50, 100, 25, 255}, {50, 100, 25, 255}, {7, 7, 7, 255}}},
+		{"ColorTemperature 6500", ColorTemperature(6500), []color.RGBA{{200, 100, 50, 255}}, []color.RGBA{{200, 100, 50, 255}}},
+		{"Blur 0", Blur(0), []color.RGBA{{200, 100, 50, 255}, {0, 0, 0, 255}}, []color.RGBA{{200, 100, 50, 255}, {0, 0, 0, 255}}},
+		{"Blur uniform", Blur(2), []color.RGBA{{9, 99, 199, 255}, {9, 99, 199, 255}, {9, 99, 199, 255}}, []color.RGBA{{9, 99, 199, 255}, {9, 99, 199, 255}, {9, 99, 199, 255}}},
+	}
+	for _, test := range tests {
+		img := test.effect.Apply(pixels(test.in...))
+		for x, want := range test.want {
+			if got := img.RGBAAt(x, 0); got != want {
+				t.Errorf("%s: pixel %d is %v, want %v", test.name, x, got, want)
+			}
+		}
+	}
+}
+
+func TestBlur(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 21, 21))
+	img.SetRGBA(10, 10, color.RGBA{255, 255, 255, 255})
+	blurred := Blur(2).Apply(img)
+
+	center := blurred.RGBAAt(10, 10).R
+	if center == 0 || center == 255 {
+		t.Errorf("center is %d after blurring", center)
+	}
+	// the spread is symmetric and fades with distance
+	for _, d := range [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}} {
+		near, far := blurred.RGBAAt(10+d[0], 10+d[1]).R, blurred.RGBAAt(10+3*d[0], 10+3*d[1]).R
+		if near != blurred.RGBAAt(11, 10).R || !(center > near && near > far) {
+			t.Errorf("direction %v: center %d, near %d, far %d", d, center, near, far)
+		}
+	}
+	if corner := blurred.RGBAAt(0, 0).R; corner != 0 {
+		t.Errorf("corner is %d, want 0", corner)
+	}
+}
+
+func TestVignette(t *testing.T) {
+	white := func() *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		for i := range img.Pix {
+			img.Pix[i] = 255
+		}
+		return img
+	}
+
+	img := Vignette(1).Apply(white())
+	if center := img.RGBAAt(50, 50); center != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("center is %v", center)
+	}
+	if edge, corner := img.RGBAAt(50, 0).R, img.RGBAAt(0, 0).R; !(corner < 10 && edge > corner && edge < 255) {
+		t.Errorf("edge is %d, corner is %d", edge, corner)
+	}
+
+	img = Vignette(0).Apply(white())
+	if corner := img.RGBAAt(0, 0); corner != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("Vignette(0) changed the corner to %v", corner)
+	}
+}
+
+func TestColorTemperature(t *testing.T) {
+	warm := ColorTemperature(3000).Apply(pixels(color.RGBA{255, 255, 255, 255})).RGBAAt(0, 0)
+	if !(warm.R == 255 && warm.G < 200 && warm.B < warm.G) {
+		t.Errorf("3000K white is %v, want orange", warm)
+	}
+	cold := ColorTemperature(10000).Apply(pixels(color.RGBA{255, 255, 255, 255})).RGBAAt(0, 0)
+	if !(cold.B == 255 && cold.R < cold.G && cold.G < cold.B) {
+		t.Errorf("10000K white is %v, want blue", cold)
+	}
+}
+
+func TestKelvinToRGB(t *testing.T) {
+	// values from the table the approximation was fitted to
+	tests := []struct {
+		kelvin  float64
+		r, g, b float64
+	}{
+		{1000, 255, 68, 0},
+		{3000, 255, 177, 110},
+		{6600, 255, 255, 255},
+		{10000, 202, 218, 255},
+	}
+	for _, test := range tests {
+		r, g, b := kelvinToRGB(test.kelvin)
+		if math.Abs(r*255-test.r) > 1.5 || math.Abs(g*255-test.g) > 1.5 || math.Abs(b*255-test.b) > 1.5 {
+			t.Errorf("kelvinToRGB(%v) = %.0f, %.0f, %.0f, want %v, %v, %v", test.kelvin, r*255, g*255, b*255, test.r, test.g, test.b)
+		}
+	}
+}
+
+func TestSetFromFileWithEffectsCache(t *testing.T) {
+	cacheDir := useTempCache(t)
+	backend := useTestBackend(t, "")
+	source := filepath.Join(t.TempDir(), "source.png")
+	writePNG(t, source, 8, 8, color.RGBA{200, 100, 50, 255})
+
+	err := SetFromFileWithEffects(source, Brightness(0.5), Blur(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered := backend.image
+	if filepath.Dir(rendered) != cacheDir {
+		t.Fatalf("set %s, want a file in the cache", rendered)
+	}
+	img, err := decodeImage(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := img.At(4, 4).RGBA(); math.Abs(float64(r>>8)-100) > 2 || math.Abs(float64(g>>8)-50) > 2 || math.Abs(float64(b>>8)-25) > 2 {
+		t.Errorf("rendered pixel is %d, %d, %d", r>>8, g>>8, b>>8)
+	}
+
+	// the same chain is rendered once, another chain to another file
+	err = SetFromFileWithEffects(source, Brightness(0.5), Blur(1))
+	if err != nil || backend.image != rendered {
+		t.Errorf("second render set %s, %v", backend.image, err)
+	}
+	err = SetFromFileWithEffects(source, Brightness(0.6), Blur(1))
+	if err != nil || backend.image == rendered {
+		t.Errorf("another chain set %s, %v", backend.image, err)
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	cacheDir := useTempCache(t)
+	old := time.Now().Add(-CacheMaxAge - time.Hour)
+	files := map[string]bool{
+		"wallpaper-0123456789abcdef.jpg": false,
+		// the current wallpaper
+		"wallpaper-fedcba9876543210.jpg": true,
+		// not rendered by the library
+		"wallpaper-overlay-0.jpg": true,
+		"photo.jpg":               true,
+	}
+	for name := range files {
+		file := filepath.Join(cacheDir, name)
+		writePNG(t, file, 1, 1, color.Black)
+		err := os.Chtimes(file, old, old)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	recent := filepath.Join(cacheDir, "wallpaper-00000000000000ff.jpg")
+	writePNG(t, recent, 1, 1, color.Black)
+	files["wallpaper-00000000000000ff.jpg"] = true
+	useTestBackend(t, "file://"+filepath.Join(cacheDir, "wallpaper-fedcba9876543210.jpg"))
+
+	pruneCache(cacheDir)
+	for name, kept := range files {
+		_, err := os.Stat(filepath.Join(cacheDir, name))
+		if (err == nil) != kept {
+			t.Errorf("%s: kept is %v, want %v", name, err == nil, kept)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Command wallpaper-backend-file is a reference wallpaper backend plugin.
+// It stores the wallpaper in the JSON file named by $WALLPAPER_BACKEND_FILE instead of a real desktop,
+// and only detects its desktop if that variable is set.
+// The monitors are read from the "monitors" list of the file and default to a single 1920x1080 monitor.
+//
+// Install it on PATH and run a program using the library outside of a supported desktop:
+//
+//	go install github.com/ktkv419/wallpaper/example/wallpaper-backend-file
+//	WALLPAPER_BACKEND_FILE=/tmp/wallpaper.json go run github.com/ktkv419/wallpaper/example
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const version = 1
+
+type request struct {
+	Version int    `json:"version"`
+	Command string `json:"command"`
+	File    string `json:"file"`
+	Mode    string `json:"mode"`
+}
+
+type response struct {
+	Version      int       `json:"version"`
+	Error        string    `json:"error,omitempty"`
+	Detected     bool      `json:"detected,omitempty"`
+	Capabilities []string  `json:"capabilities,omitempty"`
+	Image        string    `json:"image,omitempty"`
+	Mode         string    `json:"mode,omitempty"`
+	Monitors     []monitor `json:"monitors,omitempty"`
+}
+
+type monitor struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type state struct {
+	Image    string    `json:"image"`
+	Mode     string    `json:"mode"`
+	Monitors []monitor `json:"monitors,omitempty"`
+}
+
+func main() {
+	var req request
+	res, err := handle(&req)
+	if err != nil {
+		res = response{Error: err.Error()}
+	}
+
+	res.Version = version
+	err = json.NewEncoder(os.Stdout).Encode(res)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func handle(req *request) (response, error) {
+	err := json.NewDecoder(os.Stdin).Decode(req)
+	if err != nil {
+		return response{}, err
+	}
+	if req.Version != version {
+		return response{}, fmt.Errorf("unsupported protocol version %d", req.Version)
+	}
+
+	name := os.Getenv("WALLPAPER_BACKEND_FILE")
+	switch req.Command {
+	case "detect":
+		return response{Detected: name != ""}, nil
+	case "capabilities":
+		return response{Capabilities: []string{"get", "set", "set-mode", "monitors"}}, nil
+	}
+
+	var current state
+	data, err := os.ReadFile(name)
+	if err == nil {
+		err = json.Unmarshal(data, &current)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return response{}, err
+	}
+
+	switch req.Command {
+	case "get":
+		return response{Image: current.Image, Mode: current.Mode}, nil
+	case "monitors":
+		if len(current.Monitors) == 0 {
+			return response{Monitors: []monitor{{0, 0, 1920, 1080}}}, nil
+		}
+		return response{Monitors: current.Monitors}, nil
+	case "set":
+		current.Image = req.File
+	case "set-mode":
+		current.Mode = req.Mode
+	default:
+		return response{}, fmt.Errorf("unknown command %q", req.Command)
+	}
+
+	data, err = json.Marshal(current)
+	if err != nil {
+		return response{}, err
+	}
+	return response{}, os.WriteFile(name, data, 0644)
+}
@@ -0,0 +1,63 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ktkv419/wallpaper"
+	"github.com/ktkv419/wallpaper/wallpapertest"
+)
+
+// TestMain lets the test binary stand in for the plugin, so that the tests need no separate build.
+func TestMain(m *testing.M) {
+	if os.Getenv("WALLPAPER_BACKEND_FILE_PLUGIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func loadPlugin(t *testing.T) (wallpaper.Backend, string) {
+	t.Helper()
+	state := filepath.Join(t.TempDir(), "wallpaper.json")
+	os.Setenv("WALLPAPER_BACKEND_FILE", state)
+	os.Setenv("WALLPAPER_BACKEND_FILE_PLUGIN", "1")
+	t.Cleanup(func() {
+		os.Unsetenv("WALLPAPER_BACKEND_FILE")
+		os.Unsetenv("WALLPAPER_BACKEND_FILE_PLUGIN")
+	})
+
+	backend, err := wallpaper.LoadPlugin(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend, state
+}
+
+func TestConformance(t *testing.T) {
+	backend, state := loadPlugin(t)
+	err := os.WriteFile(state, []byte(`{"image": "/srv/original.jpg"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wallpapertest.TestBackend(backend, "/srv/a.jpg", "/srv/b.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var current struct{ Image string }
+	err = json.Unmarshal(data, &current)
+	if err != nil || current.Image != "/srv/original.jpg" {
+		t.Errorf("TestBackend left %s", data)
+	}
+}
@@ -0,0 +1,187 @@
+// Package fakedesktop provides an in-memory wallpaper.Backend for tests.
+package fakedesktop
+
+import (
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/ktkv419/wallpaper"
+)
+
+// Op names a Backend method for failure injection.
+type Op string
+
+const (
+	Get         Op = "Get"
+	SetFromFile Op = "SetFromFile"
+	SetMode     Op = "SetMode"
+	ModeMatches Op = "ModeMatches"
+	// SetMonitorFile and Monitors are not part of wallpaper.Backend.
+	SetMonitorFile Op = "SetMonitorFile"
+	Monitors       Op = "Monitors"
+)
+
+var _ wallpaper.Backend = (*Desktop)(nil)
+
+// Change is a successful call to SetFromFile, SetMonitorFile or SetMode.
+type Change struct {
+	Op    Op
+	Image string
+	Mode  wallpaper.Mode
+	// Monitor is the monitor changed by SetMonitorFile, numbered from 1, or 0 for every monitor.
+	Monitor int
+}
+
+// Desktop is a fake desktop which records the wallpaper instead of changing it.
+// It is safe for concurrent use.
+type Desktop struct {
+	mu       sync.Mutex
+	monitors []image.Rectangle
+	images   []string // one per monitor
+	mode     wallpaper.Mode
+	history  []Change
+	failures map[Op]error
+}
+
+// New returns a Desktop with a single 1920x1080 monitor showing file with the given mode.
+func New(file string, mode wallpaper.Mode) *Desktop {
+	return &Desktop{
+		monitors: []image.Rectangle{image.Rect(0, 0, 1920, 1080)},
+		images:   []string{file},
+		mode:     mode,
+	}
+}
+
+// Get returns the wallpaper of the first monitor.
+func (desktop *Desktop) Get() (string, error) {
+	desktop.mu.Lock()
+	defer desktop.mu.Unlock()
+
+	if err := desktop.failures[Get]; err != nil {
+		return "", err
+	}
+	return desktop.images[0], nil
+}
+
+// SetFromFile records file as the wallpaper of every monitor.
+func (desktop *Desktop) SetFromFile(file string) error {
+	desktop.mu.Lock()
+	defer desktop.mu.Unlock()
+
+	if err := desktop.failures[SetFromFile]; err != nil {
+		return err
+	}
+	for i := range desktop.images {
+		desktop.images[i] = file
+	}
+	desktop.history = append(desktop.history, Change{Op: SetFromFile, Image: file, Mode: desktop.mode})
+	return nil
+}
+
+// SetMonitorFile records file as the wallpaper of one monitor, numbered from 1 in the order of Monitors.
+func (desktop *Desktop) SetMonitorFile(monitor int, file string) error {
+	desktop.mu.Lock()
+	defer desktop.mu.Unlock()
+
+	if err := desktop.failures[SetMonitorFile]; err != nil {
+		return err
+	}
+	if monitor < 1 || monitor > len(desktop.images) {
+		return fmt.Errorf("monitor %d not found", monitor)
+	}
+	desktop.images[monitor-1] = file
+	desktop.history = append(desktop.history, Change{Op: SetMonitorFile, Image: file, Mode: desktop.mode, Monitor: monitor})
+	return nil
+}
+
+// MonitorImage returns the wallpaper of one monitor, numbered from 1, or an empty string if there is no such monitor.
+func (desktop *Desktop) MonitorImage(monitor int) string {
+	desktop.mu.Lock()
+	defer desktop.mu.Unlock()
+
+	if monitor < 1 || monitor > len(desktop.images) {
+		return ""
+	}
+	return desktop.images[monitor-1]
+}
+
+// Monitors returns the area of every monitor, like wallpaper.Monitors.
+func (desktop *Desktop) Monitors() ([]image.Rectangle, error) {
+	desktop.mu.Lock()
+	defer desktop.mu.Unlock()
+
+	if err := desktop.failures[Monitors]; err != nil {
+		return nil, err
+	}
+	return append([]image.Rectangle(nil), desktop.monitors...), nil
+}
+
+// SetMonitors replaces the monitors, as if they had been plugged in or out.
+// Every monitor shows the wallpaper of the first one.
+// It panics if no monitors are given.
+func (desktop *Desktop) SetMonitors(monitors ...image.Rectangle) {
+	desktop.mu.Lock()
+	defer desktop.mu.Unlock()
+
+	if len(monitors) == 0 {
+		panic("fakedesktop: no monitors")
+	}
+	first := desktop.images[0]
+	desktop.monitors = append([]image.Rectangle(nil), monitors...)
+	desktop.images = make([]string, len(monitors))
+	for i := range desktop.images {
+		desktop.images[i] = first
+	}
+}
+
+// SetMode records the wallpaper mode.
+func (desktop *Desktop) SetMode(mode wallpaper.Mode) error {
+	desktop.mu.Lock()
+	defer desktop.mu.Unlock()
+
+	if err := desktop.failures[SetMode]; err != nil {
+		return err
+	}
+	desktop.mode = mode
+	desktop.history = append(desktop.history, Change{Op: SetMode, Image: desktop.images[0], Mode: mode})
+	return nil
+}
+
+// ModeMatches reports whether mode is the current wallpaper mode.
+func (desktop *Desktop) ModeMatches(mode wallpaper.Mode) (bool, error) {
+	desktop.mu.Lock()
+	defer desktop.mu.Unlock()
+
+	if err := desktop.failures[ModeMatches]; err != nil {
+		return false, err
+	}
+	return desktop.mode == mode, nil
+}
+
+// Mode returns the current wallpaper mode.
+func (desktop *Desktop) Mode() wallpaper.Mode {
+	desktop.mu.Lock()
+	defer desktop.mu.Unlock()
+
+	return desktop.mode
+}
+
+// History returns every change made so far, oldest first.
+func (desktop *Desktop) History() []Change {
+	desktop.mu.Lock()
+	defer desktop.mu.Unlock()
+
+	return append([]Change(nil), desktop.history...)
+}
+
+// Fail makes every following call of op return err without changing anything, until Fail is called again with a nil error.
+func (desktop *Desktop) Fail(op Op, err error) {
+	desktop.mu.Lock()
+	defer desktop.mu.Unlock()
+
+	if desktop.failures == nil {
+		desktop.failures = map[Op]error{}
+	}
+	desktop.failures[op] = err
+}
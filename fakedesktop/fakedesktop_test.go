@@ -0,0 +1,115 @@
+package fakedesktop_test
+
+import (
+	"errors"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/ktkv419/wallpaper"
+	"github.com/ktkv419/wallpaper/fakedesktop"
+	"github.com/ktkv419/wallpaper/wallpapertest"
+)
+
+func TestConformance(t *testing.T) {
+	desktop := fakedesktop.New("/srv/original.jpg", wallpaper.Crop)
+	err := wallpapertest.TestBackend(desktop, "/srv/a.jpg", "/srv/b.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if image, _ := desktop.Get(); image != "/srv/original.jpg" {
+		t.Errorf("TestBackend left %q on the desktop", image)
+	}
+	if mode := desktop.Mode(); mode != wallpaper.Crop {
+		name, _ := mode.MarshalText()
+		t.Errorf("TestBackend left the desktop in %s mode", name)
+	}
+}
+
+// A broken backend must not pass the conformance test.
+func TestConformanceFailures(t *testing.T) {
+	for _, op := range []fakedesktop.Op{fakedesktop.Get, fakedesktop.SetFromFile, fakedesktop.SetMode, fakedesktop.ModeMatches} {
+		desktop := fakedesktop.New("/srv/original.jpg", wallpaper.Crop)
+		desktop.Fail(op, errors.New("broken"))
+
+		err := wallpapertest.TestBackend(desktop, "/srv/a.jpg", "/srv/b.jpg")
+		if err == nil || !strings.Contains(err.Error(), string(op)) {
+			t.Errorf("TestBackend with failing %s = %v", op, err)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	desktop := fakedesktop.New("/srv/original.jpg", wallpaper.Crop)
+	desktop.SetFromFile("/srv/a.jpg")
+	desktop.Fail(fakedesktop.SetMode, errors.New("broken"))
+	desktop.SetMode(wallpaper.Fit)
+	desktop.Fail(fakedesktop.SetMode, nil)
+	desktop.SetMode(wallpaper.Tile)
+
+	want := []fakedesktop.Change{
+		{Op: fakedesktop.SetFromFile, Image: "/srv/a.jpg", Mode: wallpaper.Crop},
+		{Op: fakedesktop.SetMode, Image: "/srv/a.jpg", Mode: wallpaper.Tile},
+	}
+	history := desktop.History()
+	if len(history) != len(want) {
+		t.Fatalf("history = %v, want %v", history, want)
+	}
+	for i := range want {
+		if history[i] != want[i] {
+			t.Errorf("change %d = %v, want %v", i, history[i], want[i])
+		}
+	}
+}
+
+func TestMonitors(t *testing.T) {
+	desktop := fakedesktop.New("/srv/original.jpg", wallpaper.Crop)
+	left, right := image.Rect(0, 0, 1920, 1080), image.Rect(1920, 0, 4480, 1440)
+	desktop.SetMonitors(left, right)
+
+	monitors, err := desktop.Monitors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(monitors) != 2 || monitors[0] != left || monitors[1] != right {
+		t.Errorf("Monitors() = %v", monitors)
+	}
+
+	err = desktop.SetMonitorFile(2, "/srv/right.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first, second := desktop.MonitorImage(1), desktop.MonitorImage(2); first != "/srv/original.jpg" || second != "/srv/right.jpg" {
+		t.Errorf("monitors show %q and %q", first, second)
+	}
+	if current, _ := desktop.Get(); current != "/srv/original.jpg" {
+		t.Errorf("Get() = %q, want the first monitor", current)
+	}
+
+	desktop.SetFromFile("/srv/a.jpg")
+	if first, second := desktop.MonitorImage(1), desktop.MonitorImage(2); first != "/srv/a.jpg" || second != "/srv/a.jpg" {
+		t.Errorf("after SetFromFile, monitors show %q and %q", first, second)
+	}
+
+	if err := desktop.SetMonitorFile(3, "/srv/b.jpg"); err == nil {
+		t.Error("SetMonitorFile(3) on two monitors: no error")
+	}
+	if current := desktop.MonitorImage(3); current != "" {
+		t.Errorf("MonitorImage(3) = %q", current)
+	}
+
+	desktop.Fail(fakedesktop.Monitors, errors.New("broken"))
+	if _, err := desktop.Monitors(); err == nil {
+		t.Error("failing Monitors: no error")
+	}
+
+	want := []fakedesktop.Change{
+		{Op: fakedesktop.SetMonitorFile, Image: "/srv/right.jpg", Mode: wallpaper.Crop, Monitor: 2},
+		{Op: fakedesktop.SetFromFile, Image: "/srv/a.jpg", Mode: wallpaper.Crop},
+	}
+	history := desktop.History()
+	if len(history) != len(want) || history[0] != want[0] || history[1] != want[1] {
+		t.Errorf("history = %v, want %v", history, want)
+	}
+}
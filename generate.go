@@ -0,0 +1,287 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+)
+
+// Generator renders a wallpaper procedurally at the given size.
+type Generator interface {
+	Generate(width, height int) (image.Image, error)
+}
+
+// LinearGradient blends From into To along Angle, in degrees clockwise from left to right.
+type LinearGradient struct {
+	From, To color.RGBA
+	Angle    float64
+}
+
+// RadialGradient blends Inner at the center into Outer at the corners.
+type RadialGradient struct {
+	Inner, Outer color.RGBA
+}
+
+// Plasma blends From and To using fractal value noise. Scale is the size of the largest features in pixels.
+type Plasma struct {
+	Seed     int64
+	From, To color.RGBA
+	Scale    float64
+}
+
+// Triangles fills the image with triangles of side Size whose colors vary around a From-To gradient.
+type Triangles struct {
+	Seed     int64
+	From, To color.RGBA
+	Size     float64
+}
+
+// Hexagons fills the image with hexagons of radius Size whose colors vary around a From-To gradient.
+type Hexagons struct {
+	Seed     int64
+	From, To color.RGBA
+	Size     float64
+}
+
+// Solid fills the image with Color and optionally centers a logo image or a text template on it.
+// Text is rendered like a TextBlock template. With a logo, the text moves to the bottom right corner.
+type Solid struct {
+	Color color.RGBA
+	Logo  string
+	Text  string
+}
+
+// Random returns a generator with a style and colors picked from seed.
+func Random(seed int64) Generator {
+	rng := rand.New(rand.NewSource(seed))
+	from := randomColor(rng)
+	to := randomColor(rng)
+	switch rng.Intn(5) {
+	case 0:
+		return LinearGradient{from, to, rng.Float64() * 360}
+	case 1:
+		return RadialGradient{from, to}
+	case 2:
+		return Plasma{seed, from, to, 200 + rng.Float64()*600}
+	case 3:
+		return Triangles{seed, from, to, 60 + rng.Float64()*120}
+	default:
+		return Hexagons{seed, from, to, 30 + rng.Float64()*60}
+	}
+}
+
+func randomColor(rng *rand.Rand) color.RGBA {
+	return color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+}
+
+// SetFromGenerator renders the generator at the screen resolution, caches it and calls SetFromFileWithEffects.
+// The generator runs every time and the render is cached by its pixels,
+// since templates, logos and collage files may change without the generator's fields changing.
+// Renders that stop being used, such as yesterday's date, are evicted after CacheMaxAge.
+func SetFromGenerator(generator Generator, effects ...Effect) error {
+	width, height := generatorSize()
+	img, err := generator.Generate(width, height)
+	if err != nil {
+		return err
+	}
+
+	file, err := cacheImage(toRGBA(img))
+	if err != nil {
+		return err
+	}
+
+	return SetFromFileWithEffects(file, effects...)
+}
+
+// generatorSize returns the screen size, or 1920x1080 if it can't be determined.
+func generatorSize() (int, int) {
+	width, height, err := getScreenSize()
+	if err != nil || width == 0 || height == 0 {
+		return 1920, 1080
+	}
+	return width, height
+}
+
+// fill sets every pixel to the color returned by fn.
+func fill(width, height int, fn func(x, y int) color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, fn(x, y))
+		}
+	}
+	return img
+}
+
+func lerpColor(a, b color.RGBA, t float64) color.RGBA {
+	t = math.Max(0, math.Min(1, t))
+	return color.RGBA{
+		uint8(math.Round(lerp(float64(a.R), float64(b.R), t))),
+		uint8(math.Round(lerp(float64(a.G), float64(b.G), t))),
+		uint8(math.Round(lerp(float64(a.B), float64(b.B), t))),
+		255,
+	}
+}
+
+// hash2 returns a deterministic pseudo-random number in [0, 1) for a lattice point.
+func hash2(seed int64, x, y int) float64 {
+	h := uint64(seed) ^ uint64(x)*0x9e3779b97f4a7c15 ^ uint64(y)*0xc2b2ae3d27d4eb4f
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return float64(h>>11) / (1 << 53)
+}
+
+// Generate implements Generator.
+func (gradient LinearGradient) Generate(width, height int) (image.Image, error) {
+	angle := gradient.Angle * math.Pi / 180
+	dx, dy := math.Cos(angle), math.Sin(angle)
+	// project the corners to find the extent of the gradient
+	extent := math.Abs(dx)*float64(width) + math.Abs(dy)*float64(height)
+	cx, cy := float64(width)/2, float64(height)/2
+	return fill(width, height, func(x, y int) color.RGBA {
+		t := ((float64(x)-cx)*dx+(float64(y)-cy)*dy)/extent + 0.5
+		return lerpColor(gradient.From, gradient.To, t)
+	}), nil
+}
+
+// Generate implements Generator.
+func (gradient RadialGradient) Generate(width, height int) (image.Image, error) {
+	cx, cy := float64(width)/2, float64(height)/2
+	radius := math.Hypot(cx, cy)
+	return fill(width, height, func(x, y int) color.RGBA {
+		return lerpColor(gradient.Inner, gradient.Outer, math.Hypot(float64(x)-cx, float64(y)-cy)/radius)
+	}), nil
+}
+
+// Generate implements Generator.
+func (plasma Plasma) Generate(width, height int) (image.Image, error) {
+	scale := plasma.Scale
+	if scale <= 0 {
+		scale = float64(height) / 2
+	}
+
+	return fill(width, height, func(x, y int) color.RGBA {
+		var value, amplitude, total float64 = 0, 1, 0
+		frequency := 1 / scale
+		for octave := 0; octave < 5; octave++ {
+			value += valueNoise(plasma.Seed+int64(octave), float64(x)*frequency, float64(y)*frequency) * amplitude
+			total += amplitude
+			amplitude /= 2
+			frequency *= 2
+		}
+		return lerpColor(plasma.From, plasma.To, value/total)
+	}), nil
+}
+
+// valueNoise interpolates random values on an integer lattice with a smoothstep curve.
+func valueNoise(seed int64, x, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	ix, iy := int(x0), int(y0)
+	tx, ty := smoothstep(x-x0), smoothstep(y-y0)
+	top := lerp(hash2(seed, ix, iy), hash2(seed, ix+1, iy), tx)
+	bottom := lerp(hash2(seed, ix, iy+1), hash2(seed, ix+1, iy+1), tx)
+	return lerp(top, bottom, ty)
+}
+
+func smoothstep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// Generate implements Generator.
+func (triangles Triangles) Generate(width, height int) (image.Image, error) {
+	size := triangles.Size
+	if size <= 0 {
+		size = float64(height) / 10
+	}
+
+	return fill(width, height, func(x, y int) color.RGBA {
+		fx, fy := float64(x)/size, float64(y)/size
+		cx, cy := int(math.Floor(fx)), int(math.Floor(fy))
+		u, v := fx-float64(cx), fy-float64(cy)
+
+		// each cell is split along a random diagonal
+		half := 0
+		if hash2(triangles.Seed, cx, cy) < 0.5 {
+			if u+v > 1 {
+				half = 1
+			}
+		} else if u > v {
+			half = 1
+		}
+
+		jitter := hash2(triangles.Seed+1, cx*2+half, cy) - 0.5
+		t := (float64(x)/float64(width)+float64(y)/float64(height))/2 + jitter*0.3
+		return lerpColor(triangles.From, triangles.To, t)
+	}), nil
+}
+
+// Generate implements Generator.
+func (hexagons Hexagons) Generate(width, height int) (image.Image, error) {
+	size := hexagons.Size
+	if size <= 0 {
+		size = float64(height) / 20
+	}
+
+	return fill(width, height, func(x, y int) color.RGBA {
+		// convert to axial coordinates of a pointy-top hexagon grid and round to the nearest cell
+		q := (math.Sqrt(3)/3*float64(x) - float64(y)/3) / size
+		r := 2 * float64(y) / 3 / size
+		cq, cr := hexRound(q, r)
+
+		jitter := hash2(hexagons.Seed, cq, cr) - 0.5
+		t := (float64(x)/float64(width)+float64(y)/float64(height))/2 + jitter*0.3
+		return lerpColor(hexagons.From, hexagons.To, t)
+	}), nil
+}
+
+func hexRound(q, r float64) (int, int) {
+	s := -q - r
+	rq, rr, rs := math.Round(q), math.Round(r), math.Round(s)
+	dq, dr, ds := math.Abs(rq-q), math.Abs(rr-r), math.Abs(rs-s)
+	if dq > dr && dq > ds {
+		rq = -rr - rs
+	} else if dr > ds {
+		rr = -rq - rs
+	}
+	return int(rq), int(rr)
+}
+
+// Generate implements Generator.
+func (solid Solid) Generate(width, height int) (image.Image, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(solid.Color), image.Point{}, draw.Src)
+
+	if solid.Logo != "" {
+		logo, err := decodeImage(solid.Logo)
+		if err != nil {
+			return nil, err
+		}
+
+		size := logo.Bounds().Size()
+		offset := image.Pt((width-size.X)/2, (height-size.Y)/2)
+		draw.Draw(img, logo.Bounds().Sub(logo.Bounds().Min).Add(offset), logo, logo.Bounds().Min, draw.Over)
+	}
+
+	if solid.Text != "" {
+		anchor := Middle
+		margin := 0
+		if solid.Logo != "" {
+			// keep the text clear of the logo
+			anchor = BottomRight
+			margin = height / 20
+		}
+
+		text, err := Overlay{{Template: solid.Text, Anchor: anchor, Margin: margin}}.Render()
+		if err != nil {
+			return nil, err
+		}
+		img = text.Apply(img)
+	}
+
+	return img, nil
+}
@@ -0,0 +1,149 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheImage(t *testing.T) {
+	useTempCache(t)
+
+	red := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(red.Pix); i += 4 {
+		red.Pix[i], red.Pix[i+3] = 255, 255
+	}
+	blue := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(blue.Pix); i += 4 {
+		blue.Pix[i+2], blue.Pix[i+3] = 255, 255
+	}
+
+	first, err := cacheImage(red)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := cacheImage(cloneRGBA(red))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := cacheImage(blue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != again {
+		t.Errorf("identical images cached as %q and %q", first, again)
+	}
+	if first == other {
+		t.Errorf("different images cached as %q", first)
+	}
+	if _, err := decodeImage(first); err != nil {
+		t.Errorf("cached image: %v", err)
+	}
+}
+
+// The cache must follow the render, not the generator's fields, since a logo can change on disk.
+func TestGeneratorCacheFollowsFiles(t *testing.T) {
+	useTempCache(t)
+	logo := filepath.Join(t.TempDir(), "logo.png")
+	solid := Solid{Color: color.RGBA{0, 0, 0, 255}, Logo: logo}
+
+	render := func() string {
+		img, err := solid.Generate(64, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := cacheImage(toRGBA(img))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	writePNG(t, logo, 8, 8, color.RGBA{255, 0, 0, 255})
+	first := render()
+	writePNG(t, logo, 8, 8, color.RGBA{0, 0, 255, 255})
+	second := render()
+
+	if first == second {
+		t.Errorf("changed logo rendered to the same file %q", first)
+	}
+}
+
+func TestGeneratorsDeterministic(t *testing.T) {
+	from, to := color.RGBA{200, 30, 30, 255}, color.RGBA{30, 30, 200, 255}
+	tests := map[string]func(seed int64) Generator{
+		"plasma":    func(seed int64) Generator { return Plasma{seed, from, to, 16} },
+		"triangles": func(seed int64) Generator { return Triangles{seed, from, to, 12} },
+		"hexagons":  func(seed int64) Generator { return Hexagons{seed, from, to, 8} },
+		"random":    Random,
+	}
+	for name, generator := range tests {
+		render := func(seed int64) []byte {
+			img, err := generator(seed).Generate(64, 48)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return toRGBA(img).Pix
+		}
+
+		if string(render(1)) != string(render(1)) {
+			t.Errorf("%s: the same seed rendered different images", name)
+		}
+		if string(render(1)) == string(render(2)) {
+			t.Errorf("%s: different seeds rendered the same image", name)
+		}
+	}
+}
+
+func TestGradientEndpoints(t *testing.T) {
+	from, to := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	tests := []struct {
+		name                 string
+		generator            Generator
+		start, end           image.Point
+		startColor, endColor color.RGBA
+	}{
+		{"left to right", LinearGradient{from, to, 0}, image.Pt(0, 50), image.Pt(99, 50), from, to},
+		{"top to bottom", LinearGradient{from, to, 90}, image.Pt(50, 0), image.Pt(50, 99), from, to},
+		{"right to left", LinearGradient{from, to, 180}, image.Pt(0, 50), image.Pt(99, 50), to, from},
+		{"radial", RadialGradient{from, to}, image.Pt(50, 50), image.Pt(0, 0), from, to},
+	}
+	for _, test := range tests {
+		img, err := test.generator.Generate(100, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rgba := toRGBA(img)
+		if c := rgba.RGBAAt(test.start.X, test.start.Y); !nearRGBA(c, test.startColor) {
+			t.Errorf("%s: %v at %v, want %v", test.name, c, test.start, test.startColor)
+		}
+		if c := rgba.RGBAAt(test.end.X, test.end.Y); !nearRGBA(c, test.endColor) {
+			t.Errorf("%s: %v at %v, want %v", test.name, c, test.end, test.endColor)
+		}
+	}
+}
+
+// nearRGBA reports whether a and b differ by less than a pixel's step in a 100 pixel gradient.
+func nearRGBA(a, b color.RGBA) bool {
+	near := func(x, y uint8) bool {
+		d := int(x) - int(y)
+		return d > -8 && d < 8
+	}
+	return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B)
+}
+
+func TestPlasmaStaysInRange(t *testing.T) {
+	from, to := color.RGBA{100, 100, 100, 255}, color.RGBA{150, 150, 150, 255}
+	img, err := Plasma{7, from, to, 0}.Generate(64, 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba := toRGBA(img)
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		if v := rgba.Pix[i]; v < from.R || v > to.R {
+			t.Fatalf("pixel %d is %d, outside %d-%d", i/4, v, from.R, to.R)
+		}
+	}
+}
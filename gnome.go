@@ -1,15 +1,22 @@
 package wallpaper
 
 import (
+	"net/url"
 	"os/exec"
 	"strings"
 
 	yaml "gopkg.in/yaml.v2"
 )
 
+// removeProtocol turns a file URI into a path, decoding escapes such as %20.
 func removeProtocol(input string) string {
 	if len(input) >= 7 && input[:7] == "file://" {
-		return input[7:]
+		uri, err := url.Parse(input)
+		if err != nil {
+			// not escaped, e.g. a literal % in the name
+			return input[7:]
+		}
+		return uri.Path
 	}
 	return input
 }
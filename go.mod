@@ -4,6 +4,7 @@ go 1.16
 
 require (
 	github.com/smartystreets/goconvey v1.6.4 // indirect
+	golang.org/x/image v0.0.0-20211028202545-6944b10bf410
 	golang.org/x/sys v0.0.0-20190215142949-d0b11bdaac8a
 	gopkg.in/ini.v1 v1.62.0
 	gopkg.in/yaml.v2 v2.4.0
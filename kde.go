@@ -14,6 +14,15 @@ import (
 )
 
 func getKDE() (string, error) {
+	image, err := getKDEKey("Image")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(removeProtocol(image)), nil
+}
+
+// getKDEKey returns the first value of key in the Plasma desktop config.
+func getKDEKey(key string) (string, error) {
 	usr, err := user.Current()
 	if err != nil {
 		return "", err
@@ -33,8 +42,8 @@ func getKDE() (string, error) {
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := scanner.Text()
-		if len(line) >= 6 && line[:6] == "Image=" {
-			return strings.TrimSpace(removeProtocol(line[6:])), nil
+		if strings.HasPrefix(line, key+"=") {
+			return line[len(key)+1:], nil
 		}
 	}
 	if scanner.Err() != nil {
@@ -46,7 +55,7 @@ func getKDE() (string, error) {
 		return "", err
 	}
 
-	return "", errors.New("kde image not found")
+	return "", errors.New("kde " + strings.ToLower(key) + " not found")
 }
 
 func setKDE(path string) error {
@@ -0,0 +1,136 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keyFile edits INI-style files such as KDE configs and dconf keyfiles while preserving unrelated lines.
+type keyFile struct {
+	groups []*keyGroup
+}
+
+type keyGroup struct {
+	// name is the text between the outer brackets of the header; "" for lines before the first header
+	name  string
+	lines []string
+}
+
+func readKeyFile(name string) (*keyFile, error) {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return parseKeyFile(nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseKeyFile(data), nil
+}
+
+func parseKeyFile(data []byte) *keyFile {
+	file := &keyFile{groups: []*keyGroup{{}}}
+	// an empty file would otherwise leave a blank line before the first group
+	if len(bytes.TrimSpace(data)) == 0 {
+		return file
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) >= 2 && trimmed[0] == '[' && trimmed[len(trimmed)-1] == ']' {
+			file.groups = append(file.groups, &keyGroup{name: trimmed[1 : len(trimmed)-1]})
+			continue
+		}
+
+		last := file.groups[len(file.groups)-1]
+		last.lines = append(last.lines, line)
+	}
+	return file
+}
+
+// group returns the group with the given name, appending it if it doesn't exist.
+func (file *keyFile) group(name string) *keyGroup {
+	for _, group := range file.groups {
+		if group.name == name {
+			return group
+		}
+	}
+
+	group := &keyGroup{name: name}
+	file.groups = append(file.groups, group)
+	return group
+}
+
+func (group *keyGroup) get(key string) (string, bool) {
+	for _, line := range group.lines {
+		k, v, ok := splitKey(line)
+		if ok && k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// set replaces the value of key or adds it after the last non-empty line of the group.
+func (group *keyGroup) set(key, value string) {
+	for i, line := range group.lines {
+		k, _, ok := splitKey(line)
+		if ok && k == key {
+			group.lines[i] = key + "=" + value
+			return
+		}
+	}
+
+	i := len(group.lines)
+	for i > 0 && strings.TrimSpace(group.lines[i-1]) == "" {
+		i--
+	}
+	group.lines = append(group.lines[:i], append([]string{key + "=" + value}, group.lines[i:]...)...)
+}
+
+func splitKey(line string) (string, string, bool) {
+	i := strings.IndexByte(line, '=')
+	if i == -1 || strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), true
+}
+
+func (file *keyFile) bytes() []byte {
+	var buf bytes.Buffer
+	for i, group := range file.groups {
+		if group.name != "" || i != 0 {
+			// separate a new group from the previous one
+			if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+				buf.WriteByte('\n')
+			}
+			buf.WriteString("[" + group.name + "]\n")
+		}
+		for _, line := range group.lines {
+			buf.WriteString(line + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+func (file *keyFile) write(name string) error {
+	return writeIfChanged(name, file.bytes())
+}
+
+// writeIfChanged creates parent directories and writes data, leaving the file untouched if it already matches.
+func writeIfChanged(name string, data []byte) error {
+	existing, err := os.ReadFile(name)
+	if err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0644)
+}
@@ -4,14 +4,22 @@
 package wallpaper
 
 import (
+	"errors"
+	"fmt"
+	"image"
 	"os/exec"
 	"os/user"
 	"path/filepath"
 	"strconv"
+	"strings"
 )
 
-// Get returns the current wallpaper.
+// Get returns the current wallpaper, or the video played by RunVideo.
 func Get() (string, error) {
+	if video, ok := runningVideo(); ok {
+		return video, nil
+	}
+
 	if isGNOMECompliant() {
 		return parseDconf("gsettings", "get", "org.gnome.desktop.background", "picture-uri")
 	}
@@ -30,12 +38,21 @@ func Get() (string, error) {
 	case "Deepin":
 		return parseDconf("dconf", "read", "/com/deepin/wrap/gnome/desktop/background/picture-uri")
 	default:
-		return "", ErrUnsupportedDE
+		file, err := getPlugin()
+		if err != ErrUnsupportedDE {
+			return file, err
+		}
+		return getX11()
 	}
 }
 
-// SetFromFile sets wallpaper from a file path.
+// SetFromFile sets wallpaper from a file path, stopping a video played by RunVideo.
 func SetFromFile(file string) error {
+	err := stopVideo()
+	if err != nil {
+		return err
+	}
+
 	if isGNOMECompliant() {
 		return exec.Command("gsettings", "set", "org.gnome.desktop.background", "picture-uri", strconv.Quote("file://"+file)).Run()
 	}
@@ -54,13 +71,18 @@ func SetFromFile(file string) error {
 	case "Deepin":
 		return exec.Command("dconf", "write", "/com/deepin/wrap/gnome/desktop/background/picture-uri", strconv.Quote("file://"+file)).Run()
 	default:
-		err := exec.Command("swaybg", "-i", file).Start()
+		err = setPlugin(file)
+		if err != ErrUnsupportedDE {
+			return err
+		}
+
+		err = exec.Command("swaybg", "-i", file).Start()
 		// if the command completed successfully, return
 		if err == nil {
 			return nil
 		}
 
-		return exec.Command("feh", "--bg-fill", file).Run()
+		return setX11(file)
 	}
 }
 
@@ -84,14 +106,136 @@ func SetMode(mode Mode) error {
 	case "Deepin":
 		return exec.Command("dconf", "write", "/com/deepin/wrap/gnome/desktop/background/picture-options", strconv.Quote(mode.getGNOMEString())).Run()
 	default:
+		err := setPluginMode(mode)
+		if err != ErrUnsupportedDE {
+			return err
+		}
+		return setX11Mode(mode)
+	}
+}
+
+// Monitors returns the area of every active monitor on the X screen in pixels.
+// Without an X display, it asks the backend plugin, and returns ErrUnsupportedDE if there is none.
+func Monitors() ([]image.Rectangle, error) {
+	c, err := dialX11()
+	if err == ErrUnsupportedDE {
+		return pluginMonitors()
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	return c.monitors()
+}
+
+// getDark returns the image GNOME shows with the dark style.
+func getDark() (string, error) {
+	if !isGNOMECompliant() {
+		return "", ErrUnsupportedDE
+	}
+	return parseDconf("gsettings", "get", "org.gnome.desktop.background", "picture-uri-dark")
+}
+
+// setDark sets the image GNOME shows with the dark style.
+func setDark(file string) error {
+	if !isGNOMECompliant() {
 		return ErrUnsupportedDE
 	}
+	return exec.Command("gsettings", "set", "org.gnome.desktop.background", "picture-uri-dark", strconv.Quote("file://"+file)).Run()
+}
+
+// getLockScreen returns the image shown on the GNOME or KDE lock screen.
+func getLockScreen() (string, error) {
+	switch {
+	case isGNOMECompliant():
+		return parseDconf("gsettings", "get", "org.gnome.desktop.screensaver", "picture-uri")
+	case Desktop == "KDE":
+		output, err := exec.Command("kreadconfig5", kdeLockScreenArgs...).Output()
+		if err != nil {
+			return "", err
+		}
+		return removeProtocol(strings.TrimSpace(string(output))), nil
+	default:
+		return "", ErrUnsupportedDE
+	}
+}
+
+// setLockScreen sets the image shown on the GNOME or KDE lock screen.
+func setLockScreen(file string) error {
+	switch {
+	case isGNOMECompliant():
+		return exec.Command("gsettings", "set", "org.gnome.desktop.screensaver", "picture-uri", strconv.Quote("file://"+file)).Run()
+	case Desktop == "KDE":
+		return exec.Command("kwriteconfig5", append(kdeLockScreenArgs, "file://"+file)...).Run()
+	default:
+		return ErrUnsupportedDE
+	}
+}
+
+// kdeLockScreenArgs select the lock screen image in kscreenlockerrc for kreadconfig5 and kwriteconfig5.
+var kdeLockScreenArgs = []string{"--file", "kscreenlockerrc", "--group", "Greeter", "--group", "Wallpaper", "--group", "org.kde.image", "--group", "General", "--key", "Image"}
+
+// modeMatches reports whether the desktop already uses mode.
+func modeMatches(mode Mode) (bool, error) {
+	var current, expected string
+	var err error
+	switch {
+	case isGNOMECompliant():
+		current, err = parseDconf("gsettings", "get", "org.gnome.desktop.background", "picture-options")
+		expected = mode.getGNOMEString()
+	case Desktop == "KDE":
+		current, err = getKDEKey("FillMode")
+		expected = mode.getKDEString()
+	case Desktop == "X-Cinnamon":
+		current, err = parseDconf("dconf", "read", "/org/cinnamon/desktop/background/picture-options")
+		expected = mode.getGNOMEString()
+	case Desktop == "MATE":
+		current, err = parseDconf("dconf", "read", "/org/mate/desktop/background/picture-options")
+		expected = mode.getGNOMEString()
+	case Desktop == "XFCE":
+		current, err = getXFCEValue("image-style")
+		expected = mode.getXFCEString()
+	case Desktop == "LXDE":
+		current, err = getLXDEKey("wallpaper_mode")
+		expected = mode.getLXDEString()
+	case Desktop == "Deepin":
+		current, err = parseDconf("dconf", "read", "/com/deepin/wrap/gnome/desktop/background/picture-options")
+		expected = mode.getGNOMEString()
+	default:
+		matches, err := pluginModeMatches(mode)
+		if err != ErrUnsupportedDE {
+			return matches, err
+		}
+		return x11ModeMatches(mode)
+	}
+	return current == expected, err
 }
 
 func getCacheDir() (string, error) {
+	if CacheDir != "" {
+		return CacheDir, nil
+	}
 	usr, err := user.Current()
 	if err != nil {
 		return "", err
 	}
 	return filepath.Join(usr.HomeDir, ".cache"), nil
 }
+
+// getScreenSize returns the size of the X screen, which spans all monitors.
+func getScreenSize() (int, int, error) {
+	output, err := exec.Command("xrandr", "--current").Output()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the first line looks like "Screen 0: minimum 8 x 8, current 1920 x 1080, maximum 32767 x 32767"
+	i := strings.Index(string(output), "current ")
+	if i == -1 {
+		return 0, 0, errors.New("screen size not found")
+	}
+	var width, height int
+	_, err = fmt.Sscanf(string(output[i:]), "current %d x %d", &width, &height)
+	return width, height, err
+}
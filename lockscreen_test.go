@@ -0,0 +1,69 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubGsettings keeps every key in a file named after the schema and key. Unset keys are empty strings.
+const stubGsettings = `#!/bin/sh
+file="$GSETTINGS_DIR/$2.$3"
+case "$1" in
+get) if [ -f "$file" ]; then cat "$file"; else echo "''"; fi ;;
+set) echo "$4" > "$file" ;;
+*) exit 1 ;;
+esac
+`
+
+func TestApplyLockScreen(t *testing.T) {
+	old := Desktop
+	Desktop = "GNOME"
+	t.Cleanup(func() { Desktop = old })
+
+	bin := t.TempDir()
+	err := os.WriteFile(filepath.Join(bin, "gsettings"), []byte(stubGsettings), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setEnv(t, "PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	settings := t.TempDir()
+	setEnv(t, "GSETTINGS_DIR", settings)
+
+	image := filepath.Join(t.TempDir(), "lock screen.jpg")
+	err = os.WriteFile(image, []byte("lock"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []Status{Changed, Unchanged} {
+		result, err := Apply(Manifest{LockScreen: image})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != 1 || result[0].Name != "lock screen" || result[0].Status != want {
+			t.Errorf("Apply() = %v, want the lock screen %v", result, want)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(settings, "org.gnome.desktop.screensaver.picture-uri"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != `"file://`+image+`"` {
+		t.Errorf("picture-uri = %s", got)
+	}
+	if _, err := os.Stat(filepath.Join(settings, "org.gnome.desktop.background.picture-uri")); err == nil {
+		t.Error("the desktop wallpaper was changed too")
+	}
+
+	Desktop = "LXDE"
+	_, err = Apply(Manifest{LockScreen: image})
+	if err == nil {
+		t.Error("no error on a desktop without a lock screen image")
+	}
+}
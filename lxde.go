@@ -8,6 +8,10 @@ import (
 )
 
 func getLXDE() (string, error) {
+	return getLXDEKey("wallpaper")
+}
+
+func getLXDEKey(name string) (string, error) {
 	usr, err := user.Current()
 	if err != nil {
 		return "", err
@@ -22,7 +26,7 @@ func getLXDE() (string, error) {
 		return "", err
 	}
 
-	key, err := cfg.Section("*").GetKey("wallpaper")
+	key, err := cfg.Section("*").GetKey(name)
 	if err != nil {
 		return "", err
 	}
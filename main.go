@@ -2,6 +2,7 @@ package wallpaper
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -19,6 +20,35 @@ const (
 	Tile
 )
 
+var modeNames = map[Mode]string{
+	Center:  "center",
+	Crop:    "crop",
+	Fit:     "fit",
+	Span:    "span",
+	Stretch: "stretch",
+	Tile:    "tile",
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (mode Mode) MarshalText() ([]byte, error) {
+	name, ok := modeNames[mode]
+	if !ok {
+		return nil, fmt.Errorf("invalid wallpaper mode %d", int(mode))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (mode *Mode) UnmarshalText(text []byte) error {
+	for m, name := range modeNames {
+		if name == string(text) {
+			*mode = m
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid wallpaper mode %q", text)
+}
+
 // Desktop contains the current desktop environment on Linux.
 // Empty string on all other operating systems.
 var Desktop = os.Getenv("XDG_CURRENT_DESKTOP")
@@ -29,45 +59,55 @@ var DesktopSession = os.Getenv("DESKTOP_SESSION")
 // ErrUnsupportedDE is thrown when Desktop is not a supported desktop environment.
 var ErrUnsupportedDE = errors.New("your desktop environment is not supported")
 
+// CacheDir is where downloaded and rendered wallpapers are kept.
+// If empty, the user's cache directory is used, or the temporary directory on Windows.
+var CacheDir string
+
 func downloadImage(url string) (string, error) {
-	res, err := http.Get(url)
+	cacheDir, err := getCacheDir()
 	if err != nil {
 		return "", err
 	}
-	defer res.Body.Close()
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return "", errors.New("non-200 status code")
-	}
 
-	cacheDir, err := getCacheDir()
+	file := filepath.Join(cacheDir, "wallpaper.jpg")
+	err = downloadFile(url, file)
 	if err != nil {
 		return "", err
 	}
 
-	file, err := os.Create(filepath.Join(cacheDir, "wallpaper.jpg"))
+	return file, nil
+}
+
+func downloadFile(url, name string) error {
+	res, err := http.Get(url)
 	if err != nil {
-		return "", err
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.New("non-200 status code")
 	}
 
-	_, err = io.Copy(file, res.Body)
+	file, err := os.Create(name)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	err = file.Close()
+	_, err = io.Copy(file, res.Body)
 	if err != nil {
-		return "", err
+		file.Close()
+		return err
 	}
 
-	return file.Name(), nil
+	return file.Close()
 }
 
-// SetFromURL downloads the image to a cache directory and calls SetFromFile.
+// SetFromURL downloads the image to a cache directory and sets it with the Default backend.
 func SetFromURL(url string) error {
 	file, err := downloadImage(url)
 	if err != nil {
 		return err
 	}
 
-	return SetFromFile(file)
+	return Default.SetFromFile(file)
 }
@@ -0,0 +1,90 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"sync"
+	"testing"
+)
+
+// useTempCache points the cache directory at a temporary directory for the rest of the test.
+func useTempCache(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	CacheDir = dir
+	t.Cleanup(func() { CacheDir = "" })
+	return dir
+}
+
+// setEnv sets an environment variable for the rest of the test.
+func setEnv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// writePNG writes a solid image of the given size and color.
+func writePNG(t *testing.T, name string, width, height int, c color.Color) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < width*height; i++ {
+		img.Set(i%width, i/width, c)
+	}
+
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	err = png.Encode(file, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testBackend is a Backend that only records the wallpaper, for tests that can't import fakedesktop.
+type testBackend struct {
+	mu    sync.Mutex
+	image string
+	mode  Mode
+	// getErr is returned by Get if set.
+	getErr error
+}
+
+func (b *testBackend) Get() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.image, b.getErr
+}
+
+func (b *testBackend) SetFromFile(file string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.image = file
+	return nil
+}
+
+func (b *testBackend) SetMode(mode Mode) error {
+	b.mode = mode
+	return nil
+}
+
+func (b *testBackend) ModeMatches(mode Mode) (bool, error) {
+	return b.mode == mode, nil
+}
+
+// useTestBackend makes the library use a testBackend showing image for the rest of the test.
+func useTestBackend(t *testing.T, image string) *testBackend {
+	backend := &testBackend{image: image}
+	Default = backend
+	t.Cleanup(func() { Default = System })
+	return backend
+}
@@ -0,0 +1,271 @@
+package wallpaper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Manifest is the desired wallpaper state passed to Apply.
+type Manifest struct {
+	// Image is a file path or an http(s) URL.
+	Image string `yaml:"image" json:"image"`
+	// Checksum is the optional hex-encoded SHA-256 of the image.
+	// Without it, URLs are downloaded on every Apply and only set if the download differs from the current image.
+	Checksum string `yaml:"checksum,omitempty" json:"checksum,omitempty"`
+	// Mode is left unchanged if nil.
+	Mode *Mode `yaml:"mode,omitempty" json:"mode,omitempty"`
+	// Dark is the optional image GNOME shows with the dark style, a file path or an http(s) URL.
+	// Other desktops have no dark variant, so the item fails there with ErrUnsupportedDE.
+	Dark string `yaml:"dark,omitempty" json:"dark,omitempty"`
+	// DarkChecksum is the optional hex-encoded SHA-256 of Dark.
+	DarkChecksum string `yaml:"dark_checksum,omitempty" json:"dark_checksum,omitempty"`
+	// LockScreen is the optional image of the lock screen, a file path or an http(s) URL.
+	// It is supported by GNOME, KDE and Windows, where it applies to all users and needs elevation.
+	LockScreen string `yaml:"lock_screen,omitempty" json:"lock_screen,omitempty"`
+	// LockScreenChecksum is the optional hex-encoded SHA-256 of LockScreen.
+	LockScreenChecksum string `yaml:"lock_screen_checksum,omitempty" json:"lock_screen_checksum,omitempty"`
+}
+
+// Status is the outcome of applying one item of a Manifest.
+type Status int
+
+const (
+	Unchanged Status = iota
+	Changed
+	Failed
+)
+
+func (status Status) String() string {
+	switch status {
+	case Unchanged:
+		return "unchanged"
+	case Changed:
+		return "changed"
+	case Failed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// ItemResult is the outcome of applying one item of a Manifest.
+type ItemResult struct {
+	Name   string
+	Status Status
+	Err    error
+}
+
+// Result lists the outcome of every item Apply looked at.
+type Result []ItemResult
+
+// ExitCode returns 0 if nothing changed, 2 if something changed and 1 if anything failed,
+// matching the detailed exit codes understood by configuration management tools.
+func (result Result) ExitCode() int {
+	code := 0
+	for _, item := range result {
+		switch item.Status {
+		case Failed:
+			return 1
+		case Changed:
+			code = 2
+		}
+	}
+	return code
+}
+
+// LoadManifest reads a YAML or JSON manifest. Unknown fields are rejected.
+func LoadManifest(file string) (Manifest, error) {
+	var manifest Manifest
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return manifest, err
+	}
+
+	// JSON is valid YAML
+	err = yaml.UnmarshalStrict(data, &manifest)
+	return manifest, err
+}
+
+// Apply brings the desktop to the state described by manifest, only changing what differs.
+// The returned error is the first item failure, which is also recorded in the result.
+func Apply(manifest Manifest) (Result, error) {
+	var result Result
+	var firstErr error
+	record := func(name string, status Status, err error) {
+		result = append(result, ItemResult{name, status, err})
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if manifest.Image != "" {
+		status, err := applyImage(manifest.Image, manifest.Checksum, Default.Get, Default.SetFromFile)
+		record("image", status, err)
+	}
+
+	if manifest.Mode != nil {
+		status, err := applyMode(*manifest.Mode)
+		record("mode", status, err)
+	}
+
+	if manifest.Dark != "" {
+		status, err := applyImage(manifest.Dark, manifest.DarkChecksum, getDark, setDark)
+		record("dark", status, err)
+	}
+
+	if manifest.LockScreen != "" {
+		status, err := applyImage(manifest.LockScreen, manifest.LockScreenChecksum, getLockScreen, setLockScreen)
+		record("lock screen", status, err)
+	}
+
+	return result, firstErr
+}
+
+// applyImage sets image with set unless get already returns it.
+// URLs are downloaded to a new file and verified before anything is set,
+// so a failed download never replaces the file on screen.
+func applyImage(image, checksum string, get func() (string, error), set func(string) error) (Status, error) {
+	current, err := get()
+	if err == nil && imageMatches(image, checksum, current) {
+		return Unchanged, nil
+	}
+
+	file := image
+	if isURL(file) {
+		file, err = downloadVerified(file, checksum)
+		if err != nil {
+			return Failed, err
+		}
+
+		// without a checksum, only the download tells whether the image changed
+		if checksum == "" && current != "" && sameContents(file, current) {
+			return Unchanged, nil
+		}
+	} else if checksum != "" {
+		err = verifyChecksum(file, checksum)
+		if err != nil {
+			return Failed, err
+		}
+	}
+
+	err = set(file)
+	if err != nil {
+		return Failed, err
+	}
+	return Changed, nil
+}
+
+func imageMatches(image, checksum, current string) bool {
+	if checksum != "" {
+		return verifyChecksum(current, checksum) == nil
+	}
+
+	return !isURL(image) && filepath.Clean(current) == filepath.Clean(image)
+}
+
+// downloadVerified downloads url to the cache directory under a name derived from its contents.
+// Unlike downloadImage, it never overwrites a file that may be the current wallpaper,
+// and a new image always gets a new path, which some desktops need to redraw.
+func downloadVerified(rawURL, checksum string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	cacheDir, err := getCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(cacheDir, ".wallpaper-download-*")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	err = downloadFile(rawURL, tmp.Name())
+	if err != nil {
+		return "", err
+	}
+
+	if checksum != "" {
+		err = verifyChecksum(tmp.Name(), checksum)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sum, err := fileChecksum(tmp.Name())
+	if err != nil {
+		return "", err
+	}
+	ext := path.Ext(parsed.Path)
+	if ext == "" {
+		ext = ".jpg"
+	}
+	file := filepath.Join(cacheDir, "wallpaper-"+sum[:16]+ext)
+	return file, os.Rename(tmp.Name(), file)
+}
+
+// sameContents reports whether both files exist and have the same contents.
+func sameContents(a, b string) bool {
+	sumA, err := fileChecksum(a)
+	if err != nil {
+		return false
+	}
+	sumB, err := fileChecksum(b)
+	return err == nil && sumA == sumB
+}
+
+func verifyChecksum(file, checksum string) error {
+	sum, err := fileChecksum(file)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sum, checksum) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", checksum, sum)
+	}
+	return nil
+}
+
+func applyMode(mode Mode) (Status, error) {
+	matches, err := Default.ModeMatches(mode)
+	if err == nil && matches {
+		return Unchanged, nil
+	}
+
+	err = Default.SetMode(mode)
+	if err != nil {
+		return Failed, err
+	}
+	return Changed, nil
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+func fileChecksum(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,202 @@
+package wallpaper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		data    string
+		want    Manifest
+		wantErr bool
+	}{
+		{"yaml", "image: /srv/a.jpg\nmode: fit\ndark: /srv/a-dark.jpg\n", Manifest{Image: "/srv/a.jpg", Mode: modePtr(Fit), Dark: "/srv/a-dark.jpg"}, false},
+		{"lock screen", "lock_screen: https://example.com/lock.jpg\nlock_screen_checksum: abc\n", Manifest{LockScreen: "https://example.com/lock.jpg", LockScreenChecksum: "abc"}, false},
+		{"json", `{"image": "https://example.com/a.jpg", "checksum": "abc"}`, Manifest{Image: "https://example.com/a.jpg", Checksum: "abc"}, false},
+		{"unknown field", "image: /srv/a.jpg\ncolour: red\n", Manifest{}, true},
+		{"invalid mode", "mode: sideways\n", Manifest{}, true},
+	}
+
+	for _, test := range tests {
+		file := filepath.Join(dir, test.name)
+		err := os.WriteFile(file, []byte(test.data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		manifest, err := LoadManifest(file)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !reflect.DeepEqual(manifest, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, manifest, test.want)
+		}
+	}
+}
+
+func modePtr(mode Mode) *Mode {
+	return &mode
+}
+
+func TestResultExitCode(t *testing.T) {
+	tests := []struct {
+		result Result
+		want   int
+	}{
+		{nil, 0},
+		{Result{{"image", Unchanged, nil}, {"mode", Unchanged, nil}}, 0},
+		{Result{{"image", Changed, nil}, {"mode", Unchanged, nil}}, 2},
+		{Result{{"image", Changed, nil}, {"mode", Failed, errors.New("no")}}, 1},
+	}
+	for _, test := range tests {
+		if got := test.result.ExitCode(); got != test.want {
+			t.Errorf("%v.ExitCode() = %d, want %d", test.result, got, test.want)
+		}
+	}
+}
+
+// fakeImage stands in for Get and SetFromFile.
+type fakeImage struct {
+	current string
+	sets    []string
+}
+
+func (fake *fakeImage) get() (string, error) {
+	return fake.current, nil
+}
+
+func (fake *fakeImage) set(file string) error {
+	fake.current = file
+	fake.sets = append(fake.sets, file)
+	return nil
+}
+
+func checksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestApplyImageFile(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.jpg"), filepath.Join(dir, "b.jpg")
+	for _, file := range []string{a, b} {
+		err := os.WriteFile(file, []byte(file), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fake := &fakeImage{current: a}
+	status, err := applyImage(a, "", fake.get, fake.set)
+	if status != Unchanged || err != nil || len(fake.sets) != 0 {
+		t.Errorf("applying the current image: %v, %v, sets %q", status, err, fake.sets)
+	}
+
+	status, err = applyImage(b, checksum(b), fake.get, fake.set)
+	if status != Changed || err != nil || fake.current != b {
+		t.Errorf("applying another image: %v, %v, current %q", status, err, fake.current)
+	}
+
+	fake = &fakeImage{current: a}
+	status, err = applyImage(b, checksum("something else"), fake.get, fake.set)
+	if status != Failed || err == nil || len(fake.sets) != 0 {
+		t.Errorf("applying an image with the wrong checksum: %v, %v, sets %q", status, err, fake.sets)
+	}
+}
+
+func TestApplyImageURL(t *testing.T) {
+	useTempCache(t)
+	content := "first"
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, content)
+	}))
+	defer server.Close()
+	url := server.URL + "/images/wallpaper.png?size=large"
+
+	fake := &fakeImage{}
+	status, err := applyImage(url, checksum("first"), fake.get, fake.set)
+	if status != Changed || err != nil {
+		t.Fatalf("first apply: %v, %v", status, err)
+	}
+	first := fake.current
+	if data, _ := os.ReadFile(first); string(data) != "first" || !strings.HasSuffix(first, ".png") {
+		t.Errorf("downloaded %q to %s", data, first)
+	}
+
+	// the checksum of the current image matches, so nothing is downloaded
+	status, err = applyImage(url, checksum("first"), fake.get, fake.set)
+	if status != Unchanged || err != nil || requests != 1 {
+		t.Errorf("second apply: %v, %v after %d requests", status, err, requests)
+	}
+
+	// a download with the wrong checksum must leave the image on screen alone
+	content = "tampered"
+	status, err = applyImage(url, checksum("second"), fake.get, fake.set)
+	if status != Failed || err == nil || fake.current != first {
+		t.Errorf("apply with a mismatching download: %v, %v, current %q", status, err, fake.current)
+	}
+	if data, _ := os.ReadFile(first); string(data) != "first" {
+		t.Errorf("current image overwritten with %q", data)
+	}
+
+	// new contents get a new path so that desktops redraw
+	content = "second"
+	status, err = applyImage(url, checksum("second"), fake.get, fake.set)
+	if status != Changed || err != nil || fake.current == first {
+		t.Errorf("apply with new contents: %v, %v, current %q", status, err, fake.current)
+	}
+
+	// without a checksum, the download is compared with the current image
+	second := fake.current
+	status, err = applyImage(url, "", fake.get, fake.set)
+	if status != Unchanged || err != nil || fake.current != second {
+		t.Errorf("apply of the same contents without a checksum: %v, %v, current %q", status, err, fake.current)
+	}
+	content = "third"
+	status, err = applyImage(url, "", fake.get, fake.set)
+	if status != Changed || err != nil || fake.current == second {
+		t.Errorf("apply of new contents without a checksum: %v, %v, current %q", status, err, fake.current)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("cache holds %d files, want the three different downloads", len(entries))
+	}
+
+	// the current image may have the same contents under another name
+	elsewhere := filepath.Join(t.TempDir(), "third.png")
+	err = os.WriteFile(elsewhere, []byte("third"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake = &fakeImage{current: elsewhere}
+	status, err = applyImage(url, "", fake.get, fake.set)
+	if status != Unchanged || err != nil {
+		t.Errorf("apply of the contents of the current image: %v, %v", status, err)
+	}
+	if fake.current != elsewhere {
+		t.Errorf("current image replaced with %q", fake.current)
+	}
+}
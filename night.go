@@ -0,0 +1,110 @@
+package wallpaper
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// NightSchedule describes how the wallpaper is dimmed and warmed overnight.
+// Times are wall-clock offsets from midnight, so that 19:00 is 19 hours even on daylight saving time changes,
+// and may wrap around midnight.
+type NightSchedule struct {
+	// Dusk is when the transition starts and Night is when it reaches full strength.
+	Dusk, Night time.Duration
+	// Dawn is when the transition back starts and Day is when the original is restored.
+	Dawn, Day time.Duration
+	// Steps is the number of distinct renders in each transition.
+	Steps int
+	// Brightness is the brightness factor at full strength.
+	Brightness float64
+	// Temperature is the color temperature in kelvin at full strength.
+	Temperature float64
+}
+
+// DefaultNightSchedule dims to 70% and warms to 3400K between 19:00 and 07:00.
+var DefaultNightSchedule = NightSchedule{
+	Dusk:        19 * time.Hour,
+	Night:       22 * time.Hour,
+	Dawn:        5 * time.Hour,
+	Day:         7 * time.Hour,
+	Steps:       6,
+	Brightness:  0.7,
+	Temperature: 3400,
+}
+
+// Effects returns the effect chain for time t, or nil if the original should be shown.
+func (schedule NightSchedule) Effects(t time.Time) []Effect {
+	strength := schedule.strength(t)
+	if strength == 0 {
+		return nil
+	}
+
+	return []Effect{
+		Brightness(lerp(1, schedule.Brightness, strength)),
+		ColorTemperature(lerp(6500, schedule.Temperature, strength)),
+	}
+}
+
+// strength returns how far into the night t is, from 0 to 1, rounded to the schedule's steps.
+func (schedule NightSchedule) strength(t time.Time) float64 {
+	hour, minute, second := t.Clock()
+	now := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second + time.Duration(t.Nanosecond())
+
+	var strength float64
+	switch {
+	case inRange(now, schedule.Dusk, schedule.Night):
+		strength = rangeProgress(now, schedule.Dusk, schedule.Night)
+	case inRange(now, schedule.Night, schedule.Dawn):
+		strength = 1
+	case inRange(now, schedule.Dawn, schedule.Day):
+		strength = 1 - rangeProgress(now, schedule.Dawn, schedule.Day)
+	}
+
+	steps := float64(schedule.Steps)
+	if steps < 1 {
+		steps = 1
+	}
+	return math.Round(strength*steps) / steps
+}
+
+// inRange reports whether offset lies in [start, end) on a 24-hour clock.
+func inRange(offset, start, end time.Duration) bool {
+	return rangeOffset(offset, start) < rangeOffset(end, start)
+}
+
+// rangeProgress returns how far offset is from start to end on a 24-hour clock, from 0 to 1.
+func rangeProgress(offset, start, end time.Duration) float64 {
+	return float64(rangeOffset(offset, start)) / float64(rangeOffset(end, start))
+}
+
+func rangeOffset(offset, start time.Duration) time.Duration {
+	day := 24 * time.Hour
+	return ((offset-start)%day + day) % day
+}
+
+// RunNightSchedule re-renders and sets file whenever the schedule moves to another step.
+// When ctx is done, the original file is restored.
+func RunNightSchedule(ctx context.Context, file string, schedule NightSchedule) error {
+	applied := -1.0
+	for {
+		now := time.Now()
+		strength := schedule.strength(now)
+		if strength != applied {
+			err := SetFromFileWithEffects(file, schedule.Effects(now)...)
+			if err != nil {
+				return err
+			}
+			applied = strength
+		}
+
+		select {
+		case <-ctx.Done():
+			if applied == 0 {
+				return nil
+			}
+			return Default.SetFromFile(file)
+		case <-time.After(time.Minute):
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package wallpaper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNightScheduleStrength(t *testing.T) {
+	// a schedule whose dusk wraps past midnight
+	late := NightSchedule{Dusk: 23 * time.Hour, Night: time.Hour, Dawn: 4 * time.Hour, Day: 6 * time.Hour, Steps: 4}
+
+	tests := []struct {
+		schedule NightSchedule
+		clock    string
+		want     float64
+	}{
+		{DefaultNightSchedule, "12:00", 0},
+		{DefaultNightSchedule, "18:59", 0},
+		{DefaultNightSchedule, "19:00", 0},
+		{DefaultNightSchedule, "20:00", 2.0 / 6},
+		{DefaultNightSchedule, "20:30", 0.5},
+		{DefaultNightSchedule, "22:00", 1},
+		{DefaultNightSchedule, "23:59", 1},
+		{DefaultNightSchedule, "00:00", 1},
+		{DefaultNightSchedule, "04:59", 1},
+		{DefaultNightSchedule, "06:00", 0.5},
+		{DefaultNightSchedule, "06:59", 0},
+		{DefaultNightSchedule, "07:00", 0},
+		{late, "22:59", 0},
+		{late, "23:30", 0.25},
+		{late, "00:00", 0.5},
+		{late, "02:00", 1},
+		{late, "05:00", 0.5},
+		{late, "06:00", 0},
+	}
+	for _, test := range tests {
+		clock, err := time.Parse("15:04", test.clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		day := time.Date(2024, time.June, 1, clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+		if got := test.schedule.strength(day); got != test.want {
+			t.Errorf("%+v at %s: strength %v, want %v", test.schedule, test.clock, got, test.want)
+		}
+	}
+}
+
+func TestNightScheduleDST(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+
+	// the days the clocks spring forward and fall back
+	for _, day := range []time.Time{
+		time.Date(2024, time.March, 10, 0, 0, 0, 0, location),
+		time.Date(2024, time.November, 3, 0, 0, 0, 0, location),
+	} {
+		evening := time.Date(day.Year(), day.Month(), day.Day(), 20, 30, 0, 0, location)
+		if got := DefaultNightSchedule.strength(evening); got != 0.5 {
+			t.Errorf("%v: strength %v, want 0.5", evening, got)
+		}
+	}
+}
+
+func TestInRange(t *testing.T) {
+	tests := []struct {
+		offset, start, end time.Duration
+		want               bool
+		progress           float64
+	}{
+		{20 * time.Hour, 19 * time.Hour, 22 * time.Hour, true, 1.0 / 3},
+		{19 * time.Hour, 19 * time.Hour, 22 * time.Hour, true, 0},
+		{22 * time.Hour, 19 * time.Hour, 22 * time.Hour, false, 1},
+		{18 * time.Hour, 19 * time.Hour, 22 * time.Hour, false, 23.0 / 3},
+		// wrapping past midnight
+		{23 * time.Hour, 22 * time.Hour, 2 * time.Hour, true, 0.25},
+		{time.Hour, 22 * time.Hour, 2 * time.Hour, true, 0.75},
+		{3 * time.Hour, 22 * time.Hour, 2 * time.Hour, false, 1.25},
+	}
+	for _, test := range tests {
+		if got := inRange(test.offset, test.start, test.end); got != test.want {
+			t.Errorf("inRange(%v, %v, %v) = %v", test.offset, test.start, test.end, got)
+		}
+		if got := rangeProgress(test.offset, test.start, test.end); got != test.progress {
+			t.Errorf("rangeProgress(%v, %v, %v) = %v, want %v", test.offset, test.start, test.end, got, test.progress)
+		}
+	}
+
+	if got := rangeOffset(time.Hour, 23*time.Hour); got != 2*time.Hour {
+		t.Errorf("rangeOffset(1h, 23h) = %v, want 2h", got)
+	}
+}
@@ -0,0 +1,364 @@
+package wallpaper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// AlbumArt is a Generator that fits cover art over a blurred, darkened copy of itself, with a caption underneath.
+type AlbumArt struct {
+	// Art is the path to the cover image.
+	Art  string
+	Text string
+}
+
+// Generate implements Generator.
+func (art AlbumArt) Generate(width, height int) (image.Image, error) {
+	cover, err := decodeImage(art.Art)
+	if err != nil {
+		return nil, err
+	}
+	bounds := cover.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	// blurring a small copy and scaling it up is much faster than blurring at full size
+	small := image.NewRGBA(image.Rect(0, 0, width/16+1, height/16+1))
+	xdraw.ApproxBiLinear.Scale(small, small.Bounds(), cover, centerCrop(bounds, width, height), draw.Src, nil)
+	small = Brightness(0.6).Apply(Blur(2).Apply(small))
+	xdraw.BiLinear.Scale(canvas, canvas.Bounds(), small, small.Bounds(), draw.Src, nil)
+
+	// the cover takes half the height, moved up to leave room for the caption
+	size := height / 2
+	if size > width*4/5 {
+		size = width * 4 / 5
+	}
+	scale := float64(size) / float64(bounds.Dx())
+	if bounds.Dy() > bounds.Dx() {
+		scale = float64(size) / float64(bounds.Dy())
+	}
+	fitted := image.Rect(0, 0, int(float64(bounds.Dx())*scale), int(float64(bounds.Dy())*scale))
+	fitted = fitted.Add(image.Pt((width-fitted.Dx())/2, (height-fitted.Dy())/2-height/20))
+	xdraw.CatmullRom.Scale(canvas, fitted, cover, bounds, draw.Over, nil)
+
+	if art.Text == "" {
+		return canvas, nil
+	}
+
+	parsed, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{
+		Size:    float64(height) / 30,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	drawer := font.Drawer{Dst: canvas, Src: image.NewUniform(color.White), Face: face}
+	x := (width - drawer.MeasureString(art.Text).Ceil()) / 2
+	y := fitted.Max.Y + height/30 + face.Metrics().Ascent.Ceil()
+	drawer.Dot = fixed.P(x, y)
+	drawer.DrawString(art.Text)
+	return canvas, nil
+}
+
+// RunNowPlaying shows the cover art of the playing MPRIS media player as the wallpaper until ctx is done.
+// It follows the players on the D-Bus session bus, preferring the one that changed last among those playing,
+// then among those paused. The art alternates between two files in the cache directory.
+// The previous wallpaper is restored when playback stops, the player quits or ctx is done,
+// unless it couldn't be read at the start.
+func RunNowPlaying(ctx context.Context) error {
+	// without the previous wallpaper there is nothing to restore, but the art is still shown
+	previous, err := Default.Get()
+	if err != nil {
+		previous = ""
+	}
+	previous = removeProtocol(previous)
+
+	c, err := dialSessionBus()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	// closing the connection interrupts a blocked read
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.close()
+		case <-stop:
+		}
+	}()
+
+	watcher := &mprisWatcher{conn: c, players: map[string]*mprisPlayer{}}
+	shown := AlbumArt{}
+	restore := func() error {
+		if shown == (AlbumArt{}) {
+			return nil
+		}
+		shown = AlbumArt{}
+		if previous == "" {
+			return nil
+		}
+		return Default.SetFromFile(previous)
+	}
+
+	rotation := 0
+	err = watcher.start()
+	for err == nil {
+		artURL, text := watcher.current()
+		if artURL == "" {
+			err = restore()
+		} else if art, fetchErr := fetchArt(artURL); fetchErr == nil && (AlbumArt{art, text}) != shown {
+			var img image.Image
+			img, err = AlbumArt{art, text}.Generate(generatorSize())
+			if err == nil {
+				err = setRotating("album-art", rotation, img)
+			}
+			if err == nil {
+				shown = AlbumArt{art, text}
+				rotation++
+			}
+		}
+		if err != nil {
+			break
+		}
+
+		err = watcher.wait()
+	}
+
+	restoreErr := restore()
+	if ctx.Err() != nil {
+		return restoreErr
+	}
+	return err
+}
+
+const (
+	mprisPrefix          = "org.mpris.MediaPlayer2."
+	mprisPath            = "/org/mpris/MediaPlayer2"
+	mprisPlayerInterface = "org.mpris.MediaPlayer2.Player"
+)
+
+// mprisPlayer is what RunNowPlaying knows about a media player.
+type mprisPlayer struct {
+	status   string
+	metadata map[string]interface{}
+	// changed orders the players by their last change
+	changed int
+}
+
+// mprisWatcher tracks the media players on the bus, by their unique name.
+type mprisWatcher struct {
+	conn    *dbusConn
+	players map[string]*mprisPlayer
+	changes int
+}
+
+func (w *mprisWatcher) busCall(member, signature string, args ...interface{}) ([]interface{}, error) {
+	return w.conn.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", member, signature, args...)
+}
+
+// start subscribes to changes and reads the players that are already running.
+func (w *mprisWatcher) start() error {
+	for _, rule := range []string{
+		"type='signal',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged',path='" + mprisPath + "',arg0='" + mprisPlayerInterface + "'",
+		"type='signal',sender='org.freedesktop.DBus',interface='org.freedesktop.DBus',member='NameOwnerChanged',arg0namespace='org.mpris.MediaPlayer2'",
+	} {
+		_, err := w.busCall("AddMatch", "s", rule)
+		if err != nil {
+			return err
+		}
+	}
+
+	reply, err := w.busCall("ListNames", "")
+	if err != nil {
+		return err
+	}
+	if len(reply) != 1 {
+		return errors.New("invalid d-bus ListNames reply")
+	}
+	names, _ := reply[0].([]interface{})
+	for _, name := range names {
+		name, _ := name.(string)
+		if !strings.HasPrefix(name, mprisPrefix) {
+			continue
+		}
+		reply, err := w.busCall("GetNameOwner", "s", name)
+		if err != nil || len(reply) != 1 {
+			// the player quit in the meantime
+			continue
+		}
+		owner, _ := reply[0].(string)
+		w.add(owner)
+	}
+	return nil
+}
+
+// add reads the state of a new player, ignoring players that quit before they could answer.
+func (w *mprisWatcher) add(owner string) {
+	reply, err := w.conn.call(owner, mprisPath, "org.freedesktop.DBus.Properties", "GetAll", "s", mprisPlayerInterface)
+	if err != nil || len(reply) != 1 {
+		return
+	}
+	player := &mprisPlayer{}
+	w.players[owner] = player
+	properties, _ := reply[0].([]interface{})
+	w.update(player, properties)
+}
+
+// update applies a{sv} properties to player.
+func (w *mprisWatcher) update(player *mprisPlayer, properties []interface{}) {
+	for _, property := range properties {
+		name, value, ok := dbusEntry(property)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "PlaybackStatus":
+			player.status, _ = value.(string)
+		case "Metadata":
+			entries, _ := value.([]interface{})
+			player.metadata = map[string]interface{}{}
+			for _, entry := range entries {
+				if key, value, ok := dbusEntry(entry); ok {
+					player.metadata[key] = value
+				}
+			}
+		}
+	}
+	w.changes++
+	player.changed = w.changes
+}
+
+// dbusEntry returns the key and value of an {sv} dict entry.
+func dbusEntry(entry interface{}) (string, interface{}, bool) {
+	fields, _ := entry.([]interface{})
+	if len(fields) != 2 {
+		return "", nil, false
+	}
+	key, ok := fields[0].(string)
+	variant, isVariant := fields[1].(dbusVariant)
+	return key, variant.value, ok && isVariant
+}
+
+// wait blocks until a player changes, appears or quits.
+func (w *mprisWatcher) wait() error {
+	for {
+		msg, err := w.conn.next()
+		if err != nil {
+			return err
+		}
+		if msg.typ != dbusSignal {
+			continue
+		}
+
+		switch {
+		case msg.member == "PropertiesChanged" && msg.path == mprisPath && len(msg.body) == 3:
+			player, ok := w.players[msg.sender]
+			if iface, _ := msg.body[0].(string); !ok || iface != mprisPlayerInterface {
+				continue
+			}
+			properties, _ := msg.body[1].([]interface{})
+			w.update(player, properties)
+			return nil
+		case msg.member == "NameOwnerChanged" && msg.sender == "org.freedesktop.DBus" && len(msg.body) == 3:
+			name, _ := msg.body[0].(string)
+			oldOwner, _ := msg.body[1].(string)
+			newOwner, _ := msg.body[2].(string)
+			if !strings.HasPrefix(name, mprisPrefix) {
+				continue
+			}
+			delete(w.players, oldOwner)
+			if newOwner != "" {
+				w.add(newOwner)
+			}
+			return nil
+		}
+	}
+}
+
+// current returns the art URL and caption of the player that changed last among those playing,
+// or else among those paused. The art URL is empty if no player has art to show.
+func (w *mprisWatcher) current() (string, string) {
+	rank := map[string]int{"Playing": 2, "Paused": 1}
+	var best *mprisPlayer
+	for _, player := range w.players {
+		r := rank[player.status]
+		if r == 0 {
+			continue
+		}
+		if best == nil || r > rank[best.status] || (r == rank[best.status] && player.changed > best.changed) {
+			best = player
+		}
+	}
+	if best == nil {
+		return "", ""
+	}
+
+	artURL, _ := best.metadata["mpris:artUrl"].(string)
+	title, _ := best.metadata["xesam:title"].(string)
+	var artists []string
+	list, _ := best.metadata["xesam:artist"].([]interface{})
+	for _, artist := range list {
+		if artist, ok := artist.(string); ok && artist != "" {
+			artists = append(artists, artist)
+		}
+	}
+	if len(artists) == 0 {
+		return artURL, title
+	}
+	return artURL, strings.Join(artists, ", ") + " – " + title
+}
+
+// fetchArt returns the local path of an mpris:artUrl, downloading http(s) URLs to the cache directory once.
+func fetchArt(artURL string) (string, error) {
+	parsed, err := url.Parse(artURL)
+	if err != nil {
+		return "", err
+	}
+
+	switch parsed.Scheme {
+	case "file":
+		return parsed.Path, nil
+	case "http", "https":
+		cacheDir, err := getCacheDir()
+		if err != nil {
+			return "", err
+		}
+		hash := sha256.Sum256([]byte(artURL))
+		file := filepath.Join(cacheDir, "wallpaper-art-"+hex.EncodeToString(hash[:])[:16])
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+
+		// downloaded under a temporary name so that an interrupted download isn't cached
+		err = downloadFile(artURL, file+".tmp")
+		if err != nil {
+			return "", err
+		}
+		return file, os.Rename(file+".tmp", file)
+	default:
+		return "", errors.New("unsupported art url")
+	}
+}
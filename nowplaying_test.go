@@ -0,0 +1,182 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunNowPlaying(t *testing.T) {
+	startBus(t)
+	cacheDir := useTempCache(t)
+	backend := useTestBackend(t, "/srv/previous.jpg")
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.png"), filepath.Join(dir, "second.png")
+	writePNG(t, first, 64, 48, color.RGBA{200, 50, 30, 255})
+	writePNG(t, second, 64, 48, color.RGBA{30, 60, 190, 255})
+
+	shows := func(want string) func() bool {
+		return func() bool {
+			image, _ := backend.Get()
+			return image == want
+		}
+	}
+	art := func(i int) string {
+		return filepath.Join(cacheDir, "wallpaper-album-art-"+string(rune('0'+i))+".jpg")
+	}
+
+	// a player that is already playing
+	music := startPlayer(t, "music", "Playing", "file://"+first, "Artist", "Title")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- RunNowPlaying(ctx)
+	}()
+	waitFor(t, "the first art", shows(art(0)))
+
+	music.set(t, "Stopped", "file://"+first, "Artist", "Title")
+	waitFor(t, "the previous wallpaper", shows("/srv/previous.jpg"))
+
+	// a player that starts later and quits
+	radio := startPlayer(t, "radio", "Playing", "file://"+second, "", "Radio")
+	waitFor(t, "the second art", shows(art(1)))
+	radio.quit()
+	waitFor(t, "the previous wallpaper after quitting", shows("/srv/previous.jpg"))
+
+	music.set(t, "Playing", "file://"+first, "Artist", "Title")
+	waitFor(t, "the first art again", shows(art(0)))
+
+	cancel()
+	err := <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image, _ := backend.Get(); image != "/srv/previous.jpg" {
+		t.Errorf("RunNowPlaying left %s", image)
+	}
+
+	// the art alternates between two files instead of filling the cache
+	files, _ := filepath.Glob(filepath.Join(cacheDir, "*"))
+	if len(files) != 2 || files[0] != art(0) || files[1] != art(1) {
+		t.Errorf("cache holds %v", files)
+	}
+}
+
+func TestRunNowPlayingWithoutPrevious(t *testing.T) {
+	startBus(t)
+	cacheDir := useTempCache(t)
+	backend := useTestBackend(t, "")
+	backend.getErr = errors.New("unknown desktop")
+	cover := filepath.Join(t.TempDir(), "cover.png")
+	writePNG(t, cover, 64, 48, color.RGBA{200, 50, 30, 255})
+
+	startPlayer(t, "music", "Paused", "file://"+cover, "Artist", "Title")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- RunNowPlaying(ctx)
+	}()
+	art := filepath.Join(cacheDir, "wallpaper-album-art-0.jpg")
+	waitFor(t, "the art", func() bool {
+		backend.mu.Lock()
+		defer backend.mu.Unlock()
+		return backend.image == art
+	})
+
+	cancel()
+	err := <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	// with nothing to restore, the art stays
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if backend.image != art {
+		t.Errorf("RunNowPlaying left %s", backend.image)
+	}
+}
+
+func TestRunNowPlayingWithoutBus(t *testing.T) {
+	useTestBackend(t, "/srv/previous.jpg")
+	setEnv(t, "DBUS_SESSION_BUS_ADDRESS", "")
+	err := RunNowPlaying(context.Background())
+	if err == nil {
+		t.Error("RunNowPlaying without a bus: no error")
+	}
+}
+
+func TestMPRISCurrent(t *testing.T) {
+	player := func(status string, changed int, artist ...interface{}) *mprisPlayer {
+		return &mprisPlayer{
+			status:   status,
+			changed:  changed,
+			metadata: map[string]interface{}{"mpris:artUrl": status + ".jpg", "xesam:title": "Title", "xesam:artist": artist},
+		}
+	}
+	tests := []struct {
+		name    string
+		players []*mprisPlayer
+		art     string
+		caption string
+	}{
+		{"nothing", nil, "", ""},
+		{"stopped", []*mprisPlayer{player("Stopped", 2)}, "", ""},
+		{"paused", []*mprisPlayer{player("Stopped", 2), player("Paused", 1)}, "Paused.jpg", "Title"},
+		{"playing beats paused", []*mprisPlayer{player("Paused", 2), player("Playing", 1, "A", "B")}, "Playing.jpg", "A, B – Title"},
+		{"last change", []*mprisPlayer{player("Playing", 1, "A"), player("Playing", 3, "C"), player("Playing", 2, "B")}, "Playing.jpg", "C – Title"},
+	}
+	for _, test := range tests {
+		w := &mprisWatcher{players: map[string]*mprisPlayer{}}
+		for i, p := range test.players {
+			w.players[string(rune('a'+i))] = p
+		}
+		art, caption := w.current()
+		if art != test.art || caption != test.caption {
+			t.Errorf("%s: current() = %q, %q, want %q, %q", test.name, art, caption, test.art, test.caption)
+		}
+	}
+}
+
+func TestFetchArt(t *testing.T) {
+	useTempCache(t)
+
+	file, err := fetchArt("file:///srv/music/cover%20art.jpg")
+	if err != nil || file != "/srv/music/cover art.jpg" {
+		t.Errorf("fetchArt of a file URL = %q, %v", file, err)
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("cover"))
+	}))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		file, err := fetchArt(server.URL + "/cover.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil || string(data) != "cover" {
+			t.Errorf("fetchArt wrote %q, %v", data, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("art downloaded %d times, want once", requests)
+	}
+
+	_, err = fetchArt("spotify:image:ab67616d")
+	if err == nil {
+		t.Error("fetchArt accepted a spotify URL")
+	}
+}
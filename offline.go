@@ -0,0 +1,345 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SetOffline writes the wallpaper into the configuration files of a home directory without a running session,
+// e.g. for /etc/skel or users who have never logged in.
+// It configures KDE, XFCE, LXDE and the dconf-based desktops at once and is safe to run repeatedly.
+// LXDE is configured in the default LXDE profile and in every other pcmanfm profile that already exists in home.
+//
+// dconf settings are written as a keyfile to .config/dconf/user.d/wallpaper
+// and, if dconf is installed, merged into the .config/dconf/user database.
+// Files are created with the permissions of the caller, so chown them when writing into another user's home.
+func SetOffline(home, file string, mode Mode) error {
+	err := setKDEOffline(home, file, mode)
+	if err != nil {
+		return err
+	}
+
+	err = setXFCEOffline(home, file, mode)
+	if err != nil {
+		return err
+	}
+
+	err = setLXDEOffline(home, file, mode)
+	if err != nil {
+		return err
+	}
+
+	return setDconfOffline(home, file, mode)
+}
+
+// kdeDesktopContainments returns the [Containments][<id>] groups using the image wallpaper plugin.
+// Panels name a wallpaper plugin too but never show it.
+func kdeDesktopContainments(cfg *keyFile) []string {
+	var containments []string
+	for _, group := range cfg.groups {
+		parts := strings.Split(group.name, "][")
+		if len(parts) != 2 || parts[0] != "Containments" {
+			continue
+		}
+
+		plugin, _ := group.get("plugin")
+		wallpaperPlugin, _ := group.get("wallpaperplugin")
+		if plugin != "org.kde.panel" && wallpaperPlugin == "org.kde.image" {
+			containments = append(containments, group.name)
+		}
+	}
+	return containments
+}
+
+func setKDEOffline(home, file string, mode Mode) error {
+	name := filepath.Join(home, ".config", "plasma-org.kde.plasma.desktop-appletsrc")
+	cfg, err := readKeyFile(name)
+	if err != nil {
+		return err
+	}
+
+	containments := kdeDesktopContainments(cfg)
+	if len(containments) == 0 {
+		// add a folder containment with the next free id
+		maxID := 0
+		for _, group := range cfg.groups {
+			parts := strings.Split(group.name, "][")
+			if len(parts) != 2 || parts[0] != "Containments" {
+				continue
+			}
+			if id, err := strconv.Atoi(parts[1]); err == nil && id > maxID {
+				maxID = id
+			}
+		}
+
+		containment := "Containments][" + strconv.Itoa(maxID+1)
+		group := cfg.group(containment)
+		group.set("activityId", "")
+		group.set("formfactor", "0")
+		group.set("immutability", "1")
+		group.set("lastScreen", "0")
+		group.set("location", "0")
+		group.set("plugin", "org.kde.plasma.folder")
+		group.set("wallpaperplugin", "org.kde.image")
+		containments = append(containments, containment)
+	}
+
+	for _, containment := range containments {
+		group := cfg.group(containment + "][Wallpaper][org.kde.image][General")
+		group.set("Image", "file://"+file)
+		group.set("FillMode", mode.getKDEString())
+	}
+
+	return cfg.write(name)
+}
+
+type xfconfChannel struct {
+	XMLName    xml.Name         `xml:"channel"`
+	Name       string           `xml:"name,attr"`
+	Version    string           `xml:"version,attr"`
+	Locked     string           `xml:"locked,attr,omitempty"`
+	Unlocked   string           `xml:"unlocked,attr,omitempty"`
+	Properties []xfconfProperty `xml:"property"`
+}
+
+type xfconfProperty struct {
+	Name       string           `xml:"name,attr"`
+	Type       string           `xml:"type,attr"`
+	Value      string           `xml:"value,attr,omitempty"`
+	Values     []xfconfValue    `xml:"value"`
+	Properties []xfconfProperty `xml:"property"`
+}
+
+type xfconfValue struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// property returns the child property with the given name, appending an empty one if it doesn't exist.
+func (property *xfconfProperty) property(name string) *xfconfProperty {
+	for i := range property.Properties {
+		if property.Properties[i].Name == name {
+			return &property.Properties[i]
+		}
+	}
+
+	property.Properties = append(property.Properties, xfconfProperty{Name: name, Type: "empty"})
+	return &property.Properties[len(property.Properties)-1]
+}
+
+// setBackdrop updates every last-image property below property and reports whether any was found.
+// The image-style next to each last-image is set too, and added if it's missing.
+func (property *xfconfProperty) setBackdrop(file string, mode Mode) bool {
+	found, styled := false, false
+	for i := range property.Properties {
+		child := &property.Properties[i]
+		switch child.Name {
+		case "last-image":
+			child.Type, child.Value = "string", file
+			found = true
+		case "image-style":
+			child.Type, child.Value = "int", mode.getXFCEString()
+			styled = true
+		default:
+			found = child.setBackdrop(file, mode) || found
+		}
+	}
+
+	if property.hasChild("last-image") && !styled {
+		*property.property("image-style") = xfconfProperty{Name: "image-style", Type: "int", Value: mode.getXFCEString()}
+	}
+	return found
+}
+
+func (property *xfconfProperty) hasChild(name string) bool {
+	for _, child := range property.Properties {
+		if child.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func readXfconfChannel(name, channel string) (*xfconfChannel, error) {
+	cfg := &xfconfChannel{Name: channel, Version: "1.0"}
+
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return cfg, nil
+	}
+
+	return cfg, xml.Unmarshal(data, cfg)
+}
+
+func (cfg *xfconfChannel) write(name string) error {
+	data, err := xml.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeIfChanged(name, []byte(xml.Header+string(data)+"\n"))
+}
+
+// writeXFCEBackdrop sets the wallpaper in an xfce4-desktop channel file.
+// Existing monitors and workspaces are updated; otherwise screen0/monitor0/workspace0 is created.
+func writeXFCEBackdrop(name string, file string, mode Mode, locked bool) error {
+	cfg, err := readXfconfChannel(name, "xfce4-desktop")
+	if err != nil {
+		return err
+	}
+
+	root := xfconfProperty{Properties: cfg.Properties}
+	if !root.setBackdrop(file, mode) {
+		workspace := root.property("backdrop").property("screen0").property("monitor0").property("workspace0")
+		*workspace.property("last-image") = xfconfProperty{Name: "last-image", Type: "string", Value: file}
+		*workspace.property("image-style") = xfconfProperty{Name: "image-style", Type: "int", Value: mode.getXFCEString()}
+	}
+	cfg.Properties = root.Properties
+
+	if locked {
+		cfg.Locked, cfg.Unlocked = "*", "root"
+	}
+
+	return cfg.write(name)
+}
+
+func setXFCEOffline(home, file string, mode Mode) error {
+	return writeXFCEBackdrop(filepath.Join(home, ".config", "xfce4", "xfconf", "xfce-perchannel-xml", "xfce4-desktop.xml"), file, mode, false)
+}
+
+// setLXDEOffline writes the LXDE profile and every existing pcmanfm profile, such as LXDE-pi or lubuntu.
+// The session of the caller says nothing about the sessions of home, so it isn't used.
+func setLXDEOffline(home, file string, mode Mode) error {
+	dir := filepath.Join(home, ".config", "pcmanfm")
+	profiles := []string{"LXDE"}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != "LXDE" {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+
+	for _, profile := range profiles {
+		name := filepath.Join(dir, profile, "desktop-items-0.conf")
+		cfg, err := readKeyFile(name)
+		if err != nil {
+			return err
+		}
+
+		group := cfg.group("*")
+		group.set("wallpaper_mode", mode.getLXDEString())
+		group.set("wallpaper", file)
+		err = cfg.write(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDconfKeyfile writes the wallpaper keys of every dconf-based desktop to a keyfile.
+func writeDconfKeyfile(name, file string, mode Mode) error {
+	cfg, err := readKeyFile(name)
+	if err != nil {
+		return err
+	}
+
+	setDconfKeys(cfg, file, mode)
+	return cfg.write(name)
+}
+
+func setDconfKeys(cfg *keyFile, file string, mode Mode) {
+	uri := gvariantString("file://" + file)
+	options := gvariantString(mode.getGNOMEString())
+
+	gnome := cfg.group("org/gnome/desktop/background")
+	gnome.set("picture-uri", uri)
+	gnome.set("picture-uri-dark", uri)
+	gnome.set("picture-options", options)
+
+	cinnamon := cfg.group("org/cinnamon/desktop/background")
+	cinnamon.set("picture-uri", uri)
+	cinnamon.set("picture-options", options)
+
+	mate := cfg.group("org/mate/desktop/background")
+	mate.set("picture-filename", gvariantString(file))
+	mate.set("picture-options", options)
+
+	deepin := cfg.group("com/deepin/wrap/gnome/desktop/background")
+	deepin.set("picture-uri", uri)
+	deepin.set("picture-options", options)
+}
+
+func setDconfOffline(home, file string, mode Mode) error {
+	err := writeDconfKeyfile(filepath.Join(home, ".config", "dconf", "user.d", "wallpaper"), file, mode)
+	if err != nil {
+		return err
+	}
+
+	// without dconf, none of the desktops that read the database are installed
+	if _, err := exec.LookPath("dconf"); err != nil {
+		return nil
+	}
+	return compileDconfUser(home, file, mode)
+}
+
+// compileDconfUser sets the wallpaper keys in the user database of home, keeping the other settings in it.
+// dconf compile replaces the database, so its current contents are dumped and compiled along with the keys.
+func compileDconfUser(home, file string, mode Mode) error {
+	config := filepath.Join(home, ".config")
+	db := filepath.Join(config, "dconf", "user")
+
+	tmp, err := os.MkdirTemp("", "wallpaper-dconf-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	cfg := parseKeyFile(nil)
+	if _, err := os.Stat(db); err == nil {
+		// the profile makes dconf read the database of home rather than the caller's
+		profile := filepath.Join(tmp, "profile")
+		err = os.WriteFile(profile, []byte("user-db:user\n"), 0644)
+		if err != nil {
+			return err
+		}
+
+		cmd := exec.Command("dconf", "dump", "/")
+		cmd.Env = append(os.Environ(), "DCONF_PROFILE="+profile, "XDG_CONFIG_HOME="+config)
+		dump, err := cmd.Output()
+		if err != nil {
+			return err
+		}
+		cfg = parseKeyFile(dump)
+	}
+
+	setDconfKeys(cfg, file, mode)
+	keyfiles := filepath.Join(tmp, "keyfiles")
+	err = cfg.write(filepath.Join(keyfiles, "user"))
+	if err != nil {
+		return err
+	}
+	return exec.Command("dconf", "compile", db, keyfiles).Run()
+}
+
+// gvariantString formats s as a GVariant string literal.
+func gvariantString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,193 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestSetOffline(t *testing.T) {
+	// "new" starts from an empty home, "emptyfiles" from zero-length config files
+	for _, name := range []string{"new", "existing", "emptyfiles"} {
+		t.Run(name, func(t *testing.T) {
+			// the caller's session must not decide which LXDE profile is written
+			setDesktopSession(t, "openbox")
+			// without dconf on PATH, only the keyfile is written
+			setEnv(t, "PATH", t.TempDir())
+
+			home := t.TempDir()
+			copyDir(t, filepath.Join("testdata", "offline", name, "home"), home)
+
+			err := SetOffline(home, "/usr/share/backgrounds/company.jpg", Fit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", "offline", name, "golden")
+			compareGolden(t, home, golden)
+
+			// running again must change nothing
+			err = SetOffline(home, "/usr/share/backgrounds/company.jpg", Fit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareGolden(t, home, golden)
+		})
+	}
+}
+
+// The stub dconf keeps its "database" as a plain keyfile, which is enough to check what gets compiled.
+const stubDconf = `#!/bin/sh
+case "$1" in
+dump) cat "$XDG_CONFIG_HOME/dconf/user" ;;
+compile) cat "$3"/* > "$2" ;;
+*) exit 1 ;;
+esac
+`
+
+func TestSetOfflineDconfDatabase(t *testing.T) {
+	setDesktopSession(t, "")
+	bin := t.TempDir()
+	err := os.WriteFile(filepath.Join(bin, "dconf"), []byte(stubDconf), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stub needs cat from the rest of PATH
+	setEnv(t, "PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	home := t.TempDir()
+	db := filepath.Join(home, ".config", "dconf", "user")
+	err = os.MkdirAll(filepath.Dir(db), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(db, []byte("[org/gnome/desktop/interface]\ngtk-theme='Adwaita-dark'\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second run must replace the image of the first rather than keep the existing database
+	for _, image := range []string{"/srv/first.jpg", "/srv/second.jpg"} {
+		err = SetOffline(home, image, Crop)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := parseKeyFile(data)
+		if uri, _ := cfg.group("org/gnome/desktop/background").get("picture-uri"); uri != "'file://"+image+"'" {
+			t.Errorf("picture-uri = %s after setting %s", uri, image)
+		}
+		if theme, _ := cfg.group("org/gnome/desktop/interface").get("gtk-theme"); theme != "'Adwaita-dark'" {
+			t.Errorf("gtk-theme = %q, want the existing setting kept", theme)
+		}
+	}
+}
+
+func TestKeyFileRoundTrip(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"[General]\nkey=value\n",
+		"# comment before any group\n\n[a]\nx=1\n\n[b][c]\n# keep me\ny = 2\n",
+	} {
+		got := string(parseKeyFile([]byte(input)).bytes())
+		if got != input {
+			t.Errorf("round trip of %q = %q", input, got)
+		}
+	}
+}
+
+func setDesktopSession(t *testing.T, session string) {
+	old := DesktopSession
+	DesktopSession = session
+	t.Cleanup(func() { DesktopSession = old })
+}
+
+// copyDir copies the files below src into dst. A missing src is treated as empty.
+func copyDir(t *testing.T, src, dst string) {
+	t.Helper()
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == src {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return writeIfChanged(filepath.Join(dst, rel), data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTree returns the contents of every file below dir by slash-separated relative path.
+func readTree(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)], err = os.ReadFile(path)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// compareGolden checks that dir holds exactly the files below golden, or rewrites golden with -update.
+func compareGolden(t *testing.T, dir, golden string) {
+	t.Helper()
+	got := readTree(t, dir)
+
+	if *update {
+		err := os.RemoveAll(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copyDir(t, dir, golden)
+		return
+	}
+
+	want := readTree(t, golden)
+	for name, data := range want {
+		if _, ok := got[name]; !ok {
+			t.Errorf("%s was not written", name)
+		} else if !bytes.Equal(got[name], data) {
+			t.Errorf("%s differs from the golden file:\n%s\nwant:\n%s", name, got[name], data)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("unexpected file %s", name)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package wallpaper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Anchor is the part of the image a text block is attached to.
+type Anchor int
+
+const (
+	TopLeft Anchor = iota
+	TopRight
+	BottomLeft
+	BottomRight
+	Middle
+)
+
+// TextBlock is a block of text drawn onto the wallpaper.
+type TextBlock struct {
+	// Template is a text/template executed with OverlayData.
+	// The cmd function runs a command and returns its trimmed output, e.g. {{cmd "uname" "-r"}}.
+	Template string
+	Anchor   Anchor
+	// Margin is the distance from the anchored edges in pixels.
+	Margin int
+	// Size is the font size in pixels. Defaults to 1/40 of the image height.
+	Size float64
+	// Font is the path to a TrueType or OpenType font. Defaults to the embedded Go font.
+	Font string
+	// Color is the text color. If nil, black or white is picked for contrast with the pixels underneath.
+	Color color.Color
+	// Monitor anchors the block to one monitor, numbered from 1 in the order of Monitors, instead of the whole image.
+	// It assumes the wallpaper spans all monitors, as in Span or Stretch mode.
+	Monitor int
+}
+
+// Overlay is a set of text blocks rendered onto the wallpaper.
+type Overlay []TextBlock
+
+// minRefreshInterval is the shortest interval of RunOverlay and RunDayNightMap,
+// since every refresh goes through the desktop's settings daemon.
+const minRefreshInterval = time.Second
+
+// OverlayData is the data available to TextBlock templates.
+type OverlayData struct {
+	Hostname string
+	User     string
+	IP       string
+	Date     time.Time
+	Uptime   time.Duration
+}
+
+// Render executes the templates and returns an effect that draws the resulting text.
+func (overlay Overlay) Render() (Effect, error) {
+	data, err := getOverlayData()
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := template.FuncMap{
+		"cmd": func(name string, args ...string) (string, error) {
+			output, err := exec.Command(name, args...).Output()
+			return strings.TrimSpace(string(output)), err
+		},
+	}
+
+	var monitors []image.Rectangle
+	var screen image.Rectangle
+	var blocks []renderedBlock
+	for _, block := range overlay {
+		var area image.Rectangle
+		if block.Monitor != 0 {
+			if monitors == nil {
+				monitors, err = Monitors()
+				if err != nil {
+					return nil, err
+				}
+				for _, monitor := range monitors {
+					screen = screen.Union(monitor)
+				}
+			}
+			if block.Monitor < 0 || block.Monitor > len(monitors) {
+				return nil, fmt.Errorf("monitor %d not found", block.Monitor)
+			}
+			area = monitors[block.Monitor-1]
+		}
+
+		tmpl, err := template.New("overlay").Funcs(funcs).Parse(block.Template)
+		if err != nil {
+			return nil, err
+		}
+
+		var text bytes.Buffer
+		err = tmpl.Execute(&text, data)
+		if err != nil {
+			return nil, err
+		}
+
+		fontData := goregular.TTF
+		if block.Font != "" {
+			fontData, err = os.ReadFile(block.Font)
+			if err != nil {
+				return nil, err
+			}
+		}
+		parsed, err := opentype.Parse(fontData)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, renderedBlock{block, text.String(), parsed, area, screen})
+	}
+
+	return textOverlay(blocks), nil
+}
+
+// RunOverlay re-renders the overlay onto file every interval until ctx is done.
+// An interval below one second is raised to one second.
+// Effects are applied before the overlay is drawn.
+// The renders alternate between two files in the cache directory rather than adding one per interval.
+func RunOverlay(ctx context.Context, file string, overlay Overlay, interval time.Duration, effects ...Effect) error {
+	if interval < minRefreshInterval {
+		interval = minRefreshInterval
+	}
+
+	for i := 0; ; i++ {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		text, err := overlay.Render()
+		if err != nil {
+			return err
+		}
+
+		img, err := applyEffects(source, append(append([]Effect{}, effects...), text))
+		if err != nil {
+			return err
+		}
+
+		err = setRotating("overlay", i, img)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func getOverlayData() (OverlayData, error) {
+	var data OverlayData
+	var err error
+
+	data.Hostname, err = os.Hostname()
+	if err != nil {
+		return data, err
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return data, err
+	}
+	data.User = usr.Username
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return data, err
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
+			data.IP = ipnet.IP.String()
+			break
+		}
+	}
+
+	data.Date = time.Now()
+
+	// uptime is only available on Linux
+	uptime, err := os.ReadFile("/proc/uptime")
+	if err == nil {
+		fields := strings.Fields(string(uptime))
+		if len(fields) > 0 {
+			seconds, _ := strconv.ParseFloat(fields[0], 64)
+			data.Uptime = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return data, nil
+}
+
+type renderedBlock struct {
+	TextBlock
+	text string
+	font *sfnt.Font
+	// area is the monitor in screen coordinates, and empty for the whole image
+	area, screen image.Rectangle
+}
+
+// bounds returns the part of an image with the given bounds that the block is anchored to.
+func (block renderedBlock) bounds(bounds image.Rectangle) image.Rectangle {
+	if block.area.Empty() {
+		return bounds
+	}
+
+	// the image is stretched over the screen
+	scale := func(v, min, size, screenMin, screenSize int) int {
+		return min + (v-screenMin)*size/screenSize
+	}
+	return image.Rect(
+		scale(block.area.Min.X, bounds.Min.X, bounds.Dx(), block.screen.Min.X, block.screen.Dx()),
+		scale(block.area.Min.Y, bounds.Min.Y, bounds.Dy(), block.screen.Min.Y, block.screen.Dy()),
+		scale(block.area.Max.X, bounds.Min.X, bounds.Dx(), block.screen.Min.X, block.screen.Dx()),
+		scale(block.area.Max.Y, bounds.Min.Y, bounds.Dy(), block.screen.Min.Y, block.screen.Dy()),
+	)
+}
+
+type textOverlay []renderedBlock
+
+// GoString identifies the rendered text for the effects cache, leaving out the parsed fonts.
+func (overlay textOverlay) GoString() string {
+	var key strings.Builder
+	for _, block := range overlay {
+		fmt.Fprintf(&key, "%q %d %d %g %q %#v %v %v;", block.text, block.Anchor, block.Margin, block.Size, block.Font, block.Color, block.area, block.screen)
+	}
+	return key.String()
+}
+
+// Apply implements Effect.
+func (overlay textOverlay) Apply(img *image.RGBA) *image.RGBA {
+	for _, block := range overlay {
+		bounds := block.bounds(img.Bounds())
+		size := block.Size
+		if size == 0 {
+			size = float64(bounds.Dy()) / 40
+		}
+
+		face, err := opentype.NewFace(block.font, &opentype.FaceOptions{
+			Size:    size,
+			DPI:     72,
+			Hinting: font.HintingFull,
+		})
+		if err != nil {
+			continue
+		}
+
+		lines := strings.Split(strings.TrimRight(block.text, "\n"), "\n")
+		metrics := face.Metrics()
+		lineHeight := metrics.Height.Ceil()
+		var width int
+		for _, line := range lines {
+			if w := font.MeasureString(face, line).Ceil(); w > width {
+				width = w
+			}
+		}
+		height := lineHeight * len(lines)
+
+		var origin image.Point
+		switch block.Anchor {
+		case TopLeft:
+			origin = image.Pt(bounds.Min.X+block.Margin, bounds.Min.Y+block.Margin)
+		case TopRight:
+			origin = image.Pt(bounds.Max.X-block.Margin-width, bounds.Min.Y+block.Margin)
+		case BottomLeft:
+			origin = image.Pt(bounds.Min.X+block.Margin, bounds.Max.Y-block.Margin-height)
+		case BottomRight:
+			origin = image.Pt(bounds.Max.X-block.Margin-width, bounds.Max.Y-block.Margin-height)
+		case Middle:
+			origin = image.Pt(bounds.Min.X+(bounds.Dx()-width)/2, bounds.Min.Y+(bounds.Dy()-height)/2)
+		}
+
+		textColor := block.Color
+		if textColor == nil {
+			textColor = contrastColor(img, image.Rect(origin.X, origin.Y, origin.X+width, origin.Y+height).Intersect(img.Bounds()))
+		}
+
+		drawer := font.Drawer{Dst: img, Src: image.NewUniform(textColor), Face: face}
+		for i, line := range lines {
+			drawer.Dot = fixed.P(origin.X, origin.Y+i*lineHeight+metrics.Ascent.Ceil())
+			drawer.DrawString(line)
+		}
+		face.Close()
+	}
+	return img
+}
+
+// contrastColor returns black or white, whichever stands out more against rect.
+func contrastColor(img image.Image, rect image.Rectangle) color.Color {
+	if meanLuminance(img, rect) > 0.5 {
+		return color.Black
+	}
+	return color.White
+}
@@ -0,0 +1,143 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"runtime"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// inkBounds returns the bounding box of the pixels that differ from background.
+func inkBounds(img *image.RGBA, background color.RGBA) image.Rectangle {
+	var ink image.Rectangle
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.RGBAAt(x, y) != background {
+				ink = ink.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return ink
+}
+
+func solidRGBA(width, height int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestTextOverlayAnchors(t *testing.T) {
+	goFont, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	black := color.RGBA{0, 0, 0, 255}
+
+	tests := []struct {
+		anchor Anchor
+		area   image.Rectangle
+		// the ink must lie within want
+		want image.Rectangle
+	}{
+		{TopLeft, image.Rectangle{}, image.Rect(5, 5, 100, 50)},
+		{TopRight, image.Rectangle{}, image.Rect(100, 5, 195, 50)},
+		{BottomLeft, image.Rectangle{}, image.Rect(5, 50, 100, 95)},
+		{BottomRight, image.Rectangle{}, image.Rect(100, 50, 195, 95)},
+		{Middle, image.Rectangle{}, image.Rect(50, 25, 150, 75)},
+		// the right monitor of two side by side maps to the right half of the image
+		{TopLeft, image.Rect(1920, 0, 3840, 1080), image.Rect(105, 5, 200, 50)},
+		{BottomRight, image.Rect(0, 0, 1920, 1080), image.Rect(0, 50, 95, 95)},
+	}
+	for _, test := range tests {
+		block := renderedBlock{
+			TextBlock: TextBlock{Anchor: test.anchor, Margin: 5, Size: 20, Color: color.White},
+			text:      "Hi",
+			font:      goFont,
+			area:      test.area,
+			screen:    image.Rect(0, 0, 3840, 1080),
+		}
+		img := textOverlay{block}.Apply(solidRGBA(200, 100, black))
+
+		ink := inkBounds(img, black)
+		if ink.Empty() || !ink.In(test.want) {
+			t.Errorf("anchor %d in %v: text drawn at %v, want within %v", test.anchor, test.area, ink, test.want)
+		}
+	}
+}
+
+func TestTextOverlayContrast(t *testing.T) {
+	effect, err := Overlay{{Template: "{{.User}}", Size: 20}}.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a color, the text is black on a light background and white on a dark one
+	for _, background := range []color.RGBA{{230, 230, 200, 255}, {20, 30, 60, 255}} {
+		img := effect.Apply(solidRGBA(200, 100, background))
+		ink := inkBounds(img, background)
+		if ink.Empty() {
+			t.Fatalf("no text drawn on %v", background)
+		}
+
+		want := color.RGBA{255, 255, 255, 255}
+		if background.R > 128 {
+			want = color.RGBA{0, 0, 0, 255}
+		}
+		found := false
+		for y := ink.Min.Y; y < ink.Max.Y; y++ {
+			for x := ink.Min.X; x < ink.Max.X; x++ {
+				found = found || img.RGBAAt(x, y) == want
+			}
+		}
+		if !found {
+			t.Errorf("text on %v isn't %v", background, want)
+		}
+	}
+}
+
+func TestOverlayRender(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(overlay Overlay) textOverlay {
+		t.Helper()
+		effect, err := overlay.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return effect.(textOverlay)
+	}
+
+	rendered := render(Overlay{{Template: "{{.Hostname}} {{cmd \"go\" \"env\" \"GOOS\"}}"}})
+	if want := hostname + " " + runtime.GOOS; len(rendered) != 1 || rendered[0].text != want {
+		t.Errorf("rendered %q, want %q", rendered[0].text, want)
+	}
+
+	// the cache key covers the text and the layout, but not the parsed font
+	key := rendered.GoString()
+	if again := render(Overlay{{Template: "{{.Hostname}} {{cmd \"go\" \"env\" \"GOOS\"}}"}}).GoString(); again != key {
+		t.Errorf("GoString changed between renders: %q and %q", key, again)
+	}
+	for _, other := range []Overlay{
+		{{Template: "{{.Hostname}} plan9"}},
+		{{Template: "{{.Hostname}} {{cmd \"go\" \"env\" \"GOOS\"}}", Anchor: BottomRight}},
+		{{Template: "{{.Hostname}} {{cmd \"go\" \"env\" \"GOOS\"}}", Color: color.White}},
+	} {
+		if render(other).GoString() == key {
+			t.Errorf("%+v has the same cache key as %+v", other, rendered[0].TextBlock)
+		}
+	}
+
+	_, err = Overlay{{Template: "{{.Missing"}}.Render()
+	if err == nil {
+		t.Error("Render accepted an invalid template")
+	}
+}
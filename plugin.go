@@ -0,0 +1,294 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PluginPrefix is the name prefix of external backend executables searched for on PATH.
+// They are only used when the desktop is not supported by a built-in backend.
+//
+// A plugin reads a single JSON request from stdin and writes a single JSON response to stdout:
+//
+//	{"version": 1, "command": "set", "file": "/path/to/image.jpg"}
+//	{"version": 1}
+//
+// Commands are detect, capabilities, get, set, set-mode and monitors.
+// Responses may contain "error", "detected", "capabilities", "image", "mode" and "monitors",
+// which lists the area of every monitor like {"x": 0, "y": 0, "width": 1920, "height": 1080}.
+//
+// The plugin that detects its desktop is looked up once per process and PATH,
+// so a plugin that hangs delays only the first call.
+const PluginPrefix = "wallpaper-backend-"
+
+// PluginProtocolVersion is the version of the plugin protocol, which plugins must echo back.
+const PluginProtocolVersion = 1
+
+// PluginTimeout is how long a plugin may take to answer a request.
+var PluginTimeout = 5 * time.Second
+
+// ErrPluginUnsupported is returned when a plugin doesn't support a command.
+var ErrPluginUnsupported = errors.New("command not supported by plugin")
+
+type pluginRequest struct {
+	Version int    `json:"version"`
+	Command string `json:"command"`
+	File    string `json:"file,omitempty"`
+	Mode    *Mode  `json:"mode,omitempty"`
+}
+
+type pluginResponse struct {
+	Version      int             `json:"version"`
+	Error        string          `json:"error,omitempty"`
+	Detected     bool            `json:"detected,omitempty"`
+	Capabilities []string        `json:"capabilities,omitempty"`
+	Image        string          `json:"image,omitempty"`
+	Mode         string          `json:"mode,omitempty"`
+	Monitors     []pluginMonitor `json:"monitors,omitempty"`
+}
+
+type pluginMonitor struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type plugin struct {
+	path         string
+	capabilities []string
+}
+
+// findPlugins returns the plugin executables on PATH, sorted by name.
+// Earlier PATH entries shadow later ones with the same name.
+func findPlugins() []string {
+	seen := map[string]bool{}
+	var names, plugins []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		matches, _ := filepath.Glob(filepath.Join(dir, PluginPrefix+"*"))
+		for _, match := range matches {
+			name := filepath.Base(match)
+			info, err := os.Stat(match)
+			if err != nil || info.IsDir() || info.Mode()&0111 == 0 || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		path, err := exec.LookPath(name)
+		if err == nil {
+			plugins = append(plugins, path)
+		}
+	}
+	return plugins
+}
+
+// detected caches the result of detectPlugin for the PATH it searched.
+var detected struct {
+	sync.Mutex
+	done   bool
+	path   string
+	plugin *plugin
+	err    error
+}
+
+// detectPlugin returns the first plugin that detects its desktop.
+func detectPlugin() (*plugin, error) {
+	detected.Lock()
+	defer detected.Unlock()
+
+	path := os.Getenv("PATH")
+	if !detected.done || detected.path != path {
+		detected.plugin, detected.err = findDetectedPlugin()
+		detected.done, detected.path = true, path
+	}
+	return detected.plugin, detected.err
+}
+
+func findDetectedPlugin() (*plugin, error) {
+	for _, path := range findPlugins() {
+		p := &plugin{path: path}
+		response, err := p.call(pluginRequest{Command: "detect"})
+		if err != nil || !response.Detected {
+			continue
+		}
+
+		response, err = p.call(pluginRequest{Command: "capabilities"})
+		if err != nil {
+			return nil, err
+		}
+		p.capabilities = response.Capabilities
+		return p, nil
+	}
+	return nil, ErrUnsupportedDE
+}
+
+func (p *plugin) supports(command string) bool {
+	for _, capability := range p.capabilities {
+		if capability == command {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *plugin) call(request pluginRequest) (pluginResponse, error) {
+	var response pluginResponse
+	request.Version = PluginProtocolVersion
+	input, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), PluginTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.path)
+	cmd.Stdin = bytes.NewReader(input)
+	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return response, fmt.Errorf("%s: %s timed out", filepath.Base(p.path), request.Command)
+	}
+	if err != nil {
+		return response, fmt.Errorf("%s: %s: %w", filepath.Base(p.path), request.Command, err)
+	}
+
+	err = json.Unmarshal(output, &response)
+	if err != nil {
+		return response, fmt.Errorf("%s: %s: %w", filepath.Base(p.path), request.Command, err)
+	}
+	if response.Version != PluginProtocolVersion {
+		return response, fmt.Errorf("%s: unsupported protocol version %d", filepath.Base(p.path), response.Version)
+	}
+	if response.Error != "" {
+		return response, fmt.Errorf("%s: %s: %s", filepath.Base(p.path), request.Command, response.Error)
+	}
+	return response, nil
+}
+
+// command checks that the plugin supports the request's command before calling it.
+func (p *plugin) command(request pluginRequest) (pluginResponse, error) {
+	if !p.supports(request.Command) {
+		return pluginResponse{}, ErrPluginUnsupported
+	}
+	return p.call(request)
+}
+
+// LoadPlugin returns the plugin with the given executable name or path as a Backend,
+// whether or not it detects its desktop.
+func LoadPlugin(name string) (Backend, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plugin{path: path}
+	response, err := p.call(pluginRequest{Command: "capabilities"})
+	if err != nil {
+		return nil, err
+	}
+	p.capabilities = response.Capabilities
+	return p, nil
+}
+
+func (p *plugin) Get() (string, error) {
+	response, err := p.command(pluginRequest{Command: "get"})
+	return response.Image, err
+}
+
+func (p *plugin) SetFromFile(file string) error {
+	_, err := p.command(pluginRequest{Command: "set", File: file})
+	return err
+}
+
+func (p *plugin) SetMode(mode Mode) error {
+	_, err := p.command(pluginRequest{Command: "set-mode", Mode: &mode})
+	return err
+}
+
+// Monitors returns the monitors reported by the plugin.
+func (p *plugin) Monitors() ([]image.Rectangle, error) {
+	response, err := p.command(pluginRequest{Command: "monitors"})
+	if err != nil {
+		return nil, err
+	}
+
+	var monitors []image.Rectangle
+	for _, monitor := range response.Monitors {
+		monitors = append(monitors, image.Rect(monitor.X, monitor.Y, monitor.X+monitor.Width, monitor.Y+monitor.Height))
+	}
+	return monitors, nil
+}
+
+// ModeMatches compares against the mode reported by get, which plugins may leave out.
+func (p *plugin) ModeMatches(mode Mode) (bool, error) {
+	response, err := p.command(pluginRequest{Command: "get"})
+	if err != nil {
+		return false, err
+	}
+	name, _ := mode.MarshalText()
+	return strings.EqualFold(response.Mode, string(name)), nil
+}
+
+func getPlugin() (string, error) {
+	p, err := detectPlugin()
+	if err != nil {
+		return "", err
+	}
+	return p.Get()
+}
+
+func setPlugin(file string) error {
+	p, err := detectPlugin()
+	if err != nil {
+		return err
+	}
+	return p.SetFromFile(file)
+}
+
+func setPluginMode(mode Mode) error {
+	p, err := detectPlugin()
+	if err != nil {
+		return err
+	}
+	return p.SetMode(mode)
+}
+
+// pluginMonitors returns ErrUnsupportedDE if no plugin reports the monitors.
+func pluginMonitors() ([]image.Rectangle, error) {
+	p, err := detectPlugin()
+	if err != nil {
+		return nil, err
+	}
+	monitors, err := p.Monitors()
+	if err == ErrPluginUnsupported {
+		return nil, ErrUnsupportedDE
+	}
+	return monitors, err
+}
+
+func pluginModeMatches(mode Mode) (bool, error) {
+	p, err := detectPlugin()
+	if err != nil {
+		return false, err
+	}
+	return p.ModeMatches(mode)
+}
@@ -0,0 +1,231 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildPlugin builds the reference plugin into a new directory meant to be put on PATH.
+func buildPlugin(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found on PATH")
+	}
+
+	dir := t.TempDir()
+	output, err := exec.Command("go", "build", "-o", filepath.Join(dir, "wallpaper-backend-file"), "./example/wallpaper-backend-file").CombinedOutput()
+	if err != nil {
+		t.Fatalf("building the reference plugin: %v\n%s", err, output)
+	}
+	return dir
+}
+
+// writePlugin writes a shell script plugin into dir.
+func writePlugin(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, PluginPrefix+name)
+	err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// forgetPlugin drops the cached result of detectPlugin, which only notices changes to PATH.
+func forgetPlugin() {
+	detected.Lock()
+	detected.done = false
+	detected.Unlock()
+}
+
+func TestReferencePlugin(t *testing.T) {
+	dir := buildPlugin(t)
+	setEnv(t, "PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	state := filepath.Join(t.TempDir(), "wallpaper.json")
+
+	// the reference plugin only detects its desktop with WALLPAPER_BACKEND_FILE set
+	setEnv(t, "WALLPAPER_BACKEND_FILE", "")
+	_, err := detectPlugin()
+	if err != ErrUnsupportedDE {
+		t.Fatalf("detectPlugin without a desktop = %v, want ErrUnsupportedDE", err)
+	}
+
+	os.Setenv("WALLPAPER_BACKEND_FILE", state)
+	forgetPlugin()
+	p, err := detectPlugin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"get", "set", "set-mode", "monitors"}; !reflect.DeepEqual(p.capabilities, want) {
+		t.Errorf("capabilities = %q, want %q", p.capabilities, want)
+	}
+
+	err = setPlugin("/srv/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = setPluginMode(Tile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := getPlugin()
+	if err != nil || current != "/srv/a.jpg" {
+		t.Errorf("getPlugin = %q, %v", current, err)
+	}
+	for mode, want := range map[Mode]bool{Tile: true, Fit: false} {
+		matches, err := pluginModeMatches(mode)
+		if err != nil || matches != want {
+			t.Errorf("pluginModeMatches(%d) = %v, %v, want %v", mode, matches, err, want)
+		}
+	}
+
+	// without an X display, the plugin reports the monitors
+	setEnv(t, "DISPLAY", "")
+	monitors, err := Monitors()
+	if err != nil || !reflect.DeepEqual(monitors, []image.Rectangle{image.Rect(0, 0, 1920, 1080)}) {
+		t.Errorf("Monitors() = %v, %v, want the default monitor", monitors, err)
+	}
+	err = os.WriteFile(state, []byte(`{"monitors": [{"x": 0, "y": 0, "width": 1280, "height": 1024}, {"x": 1280, "y": 0, "width": 2560, "height": 1440}]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	monitors, err = Monitors()
+	if want := []image.Rectangle{image.Rect(0, 0, 1280, 1024), image.Rect(1280, 0, 3840, 1440)}; err != nil || !reflect.DeepEqual(monitors, want) {
+		t.Errorf("Monitors() = %v, %v, want %v", monitors, err, want)
+	}
+
+	// the plugin itself must reject requests for another protocol version
+	cmd := exec.Command(p.path)
+	cmd.Stdin = strings.NewReader(`{"version": 2, "command": "get"}`)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response pluginResponse
+	err = json.Unmarshal(output, &response)
+	if err != nil || !strings.Contains(response.Error, "version") {
+		t.Errorf("request with version 2 answered with %s", bytes.TrimSpace(output))
+	}
+}
+
+func TestPluginErrors(t *testing.T) {
+	dir := t.TempDir()
+	setEnv(t, "PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	oldTimeout := PluginTimeout
+	PluginTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { PluginTimeout = oldTimeout })
+
+	tests := []struct {
+		name   string
+		script string
+		call   func(Backend) error
+		check  func(error) bool
+	}{
+		{
+			"newer protocol",
+			`echo '{"version": 2, "capabilities": ["get"]}'`,
+			nil,
+			func(err error) bool {
+				return err != nil && strings.Contains(err.Error(), "unsupported protocol version 2")
+			},
+		},
+		{
+			"timeout",
+			// exec, so that killing the plugin closes its stdout
+			"exec sleep 10\n",
+			nil,
+			func(err error) bool { return err != nil && strings.Contains(err.Error(), "timed out") },
+		},
+		{
+			"unsupported command",
+			`echo '{"version": 1, "capabilities": ["get"]}'`,
+			func(b Backend) error { return b.SetFromFile("/srv/a.jpg") },
+			func(err error) bool { return errors.Is(err, ErrPluginUnsupported) },
+		},
+		{
+			"error response",
+			`read request
+case "$request" in
+*capabilities*) echo '{"version": 1, "capabilities": ["get"]}' ;;
+*) echo '{"version": 1, "error": "no monitors"}' ;;
+esac
+`,
+			func(b Backend) error { _, err := b.Get(); return err },
+			func(err error) bool { return err != nil && strings.Contains(err.Error(), "no monitors") },
+		},
+	}
+
+	for _, test := range tests {
+		path := writePlugin(t, dir, strings.ReplaceAll(test.name, " ", "-"), test.script)
+		started := time.Now()
+		backend, err := LoadPlugin(path)
+		if err == nil && test.call != nil {
+			err = test.call(backend)
+		}
+
+		if !test.check(err) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if time.Since(started) > 2*time.Second {
+			t.Errorf("%s: took %v", test.name, time.Since(started))
+		}
+	}
+}
+
+func TestDetectPluginCached(t *testing.T) {
+	dir := t.TempDir()
+	setEnv(t, "PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	log := filepath.Join(dir, "log")
+	setEnv(t, "PLUGIN_LOG", log)
+
+	oldTimeout := PluginTimeout
+	PluginTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { PluginTimeout = oldTimeout })
+
+	// a plugin that hangs while detecting comes first
+	writePlugin(t, dir, "a-hung", "exec sleep 10\n")
+	writePlugin(t, dir, "b-logged", `read request
+echo "$request" >> "$PLUGIN_LOG"
+case "$request" in
+*detect*) echo '{"version": 1, "detected": true}' ;;
+*capabilities*) echo '{"version": 1, "capabilities": ["get"]}' ;;
+*) echo '{"version": 1, "image": "/srv/a.jpg"}' ;;
+esac
+`)
+
+	started := time.Now()
+	for i := 0; i < 3; i++ {
+		current, err := getPlugin()
+		if err != nil || current != "/srv/a.jpg" {
+			t.Fatalf("getPlugin = %q, %v", current, err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed > 2*PluginTimeout {
+		t.Errorf("3 calls took %v, want a single timeout of the hung plugin", elapsed)
+	}
+
+	var commands []string
+	for _, line := range readLines(log) {
+		var request pluginRequest
+		if json.Unmarshal([]byte(line), &request) == nil {
+			commands = append(commands, request.Command)
+		}
+	}
+	if want := []string{"detect", "capabilities", "get", "get", "get"}; !reflect.DeepEqual(commands, want) {
+		t.Errorf("commands = %q, want %q", commands, want)
+	}
+}
@@ -0,0 +1,235 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// dconfLockedKeys are the keys users are prevented from changing by SetPolicy.
+var dconfLockedKeys = []string{
+	"/org/gnome/desktop/background/picture-uri",
+	"/org/gnome/desktop/background/picture-uri-dark",
+	"/org/gnome/desktop/background/picture-options",
+	"/org/cinnamon/desktop/background/picture-uri",
+	"/org/cinnamon/desktop/background/picture-options",
+	"/org/mate/desktop/background/picture-filename",
+	"/org/mate/desktop/background/picture-options",
+	"/com/deepin/wrap/gnome/desktop/background/picture-uri",
+	"/com/deepin/wrap/gnome/desktop/background/picture-options",
+}
+
+// SetPolicy enforces the wallpaper for every user of the system below root, which is "/" on a live system.
+// It writes a locked dconf system database for GNOME-family desktops,
+// kiosk-immutable wallpapers for the KDE desktop containments
+// and a locked xfce4-desktop channel for XFCE.
+//
+// KDE numbers desktop containments per user, so every id found in the appletsrc files
+// of /etc/skel, /root and /home/* below root is locked, along with the default id 1.
+// Run SetPolicy again after users add monitors or activities.
+// When root is "/" and dconf is installed, the dconf database is updated; otherwise run `dconf update` inside root.
+func SetPolicy(root, file string, mode Mode) error {
+	err := setDconfPolicy(root, file, mode)
+	if err != nil {
+		return err
+	}
+
+	err = setKDEPolicy(root, file, mode)
+	if err != nil {
+		return err
+	}
+
+	return writeXFCEBackdrop(filepath.Join(root, "etc", "xdg", "xfce4", "xfconf", "xfce-perchannel-xml", "xfce4-desktop.xml"), file, mode, true)
+}
+
+// Locked reports whether the wallpaper is locked by policy, in which case SetFromFile will be refused or ignored.
+func Locked() (bool, error) {
+	if isGNOMECompliant() {
+		return gsettingsLocked("org.gnome.desktop.background", "picture-uri")
+	}
+
+	switch Desktop {
+	case "KDE":
+		usr, err := user.Current()
+		if err != nil {
+			return false, err
+		}
+		return kdeLocked("/", usr.HomeDir)
+	case "X-Cinnamon":
+		return gsettingsLocked("org.cinnamon.desktop.background", "picture-uri")
+	case "MATE":
+		return gsettingsLocked("org.mate.background", "picture-filename")
+	case "XFCE":
+		return xfceLocked("/")
+	case "Deepin":
+		return gsettingsLocked("com.deepin.wrap.gnome.desktop.background", "picture-uri")
+	default:
+		return false, nil
+	}
+}
+
+func setDconfPolicy(root, file string, mode Mode) error {
+	dbDir := filepath.Join(root, "etc", "dconf", "db", "local.d")
+	err := writeDconfKeyfile(filepath.Join(dbDir, "00-wallpaper"), file, mode)
+	if err != nil {
+		return err
+	}
+
+	err = writeIfChanged(filepath.Join(dbDir, "locks", "wallpaper"), []byte(strings.Join(dconfLockedKeys, "\n")+"\n"))
+	if err != nil {
+		return err
+	}
+
+	// the local database is only read if the user profile lists it
+	profile := filepath.Join(root, "etc", "dconf", "profile", "user")
+	data, err := os.ReadFile(profile)
+	if os.IsNotExist(err) {
+		data, err = []byte("user-db:user\n"), nil
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.Contains("\n"+string(data), "\nsystem-db:local\n") {
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			data = append(data, '\n')
+		}
+		err = writeIfChanged(profile, append(data, "system-db:local\n"...))
+		if err != nil {
+			return err
+		}
+	}
+
+	if filepath.Clean(root) != "/" {
+		return nil
+	}
+	// without dconf, none of the desktops that read the database are installed
+	if _, err := exec.LookPath("dconf"); err != nil {
+		return nil
+	}
+	return exec.Command("dconf", "update").Run()
+}
+
+func kdePolicyFile(root string) string {
+	return filepath.Join(root, "etc", "xdg", "plasma-org.kde.plasma.desktop-appletsrc")
+}
+
+func setKDEPolicy(root, file string, mode Mode) error {
+	ids, err := kdeContainmentIDs(root)
+	if err != nil {
+		return err
+	}
+
+	name := kdePolicyFile(root)
+	cfg, err := readKeyFile(name)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		// [$i] marks the group immutable, so user settings for it are ignored
+		group := cfg.group("Containments][" + id + "][Wallpaper][org.kde.image][General][$i")
+		group.set("Image", "file://"+file)
+		group.set("FillMode", mode.getKDEString())
+	}
+	return cfg.write(name)
+}
+
+// kdeContainmentIDs returns the ids of the desktop containments of the users below root, starting with the default 1.
+func kdeContainmentIDs(root string) ([]string, error) {
+	homes, err := filepath.Glob(filepath.Join(root, "home", "*"))
+	if err != nil {
+		return nil, err
+	}
+	homes = append([]string{filepath.Join(root, "etc", "skel"), filepath.Join(root, "root")}, homes...)
+
+	ids := []string{"1"}
+	seen := map[string]bool{"1": true}
+	for _, home := range homes {
+		cfg, err := readKeyFile(filepath.Join(home, ".config", "plasma-org.kde.plasma.desktop-appletsrc"))
+		if err != nil {
+			// other users' homes may not be readable
+			continue
+		}
+
+		for _, containment := range kdeDesktopContainments(cfg) {
+			id := strings.TrimPrefix(containment, "Containments][")
+			if !seen[id] {
+				ids = append(ids, id)
+				seen[id] = true
+			}
+		}
+	}
+	return ids, nil
+}
+
+// kdeLocked reports whether every desktop containment of the user with the given home has a locked wallpaper below root.
+func kdeLocked(root, home string) (bool, error) {
+	cfg, err := readKeyFile(kdePolicyFile(root))
+	if err != nil {
+		return false, err
+	}
+
+	userCfg, err := readKeyFile(filepath.Join(home, ".config", "plasma-org.kde.plasma.desktop-appletsrc"))
+	if err != nil {
+		return false, err
+	}
+	containments := kdeDesktopContainments(userCfg)
+	if len(containments) == 0 {
+		// the user hasn't logged in yet and will get containment 1
+		containments = []string{"Containments][1"}
+	}
+
+	for _, containment := range containments {
+		prefix := containment + "][Wallpaper][org.kde.image"
+		locked := false
+		for _, group := range cfg.groups {
+			if !strings.HasPrefix(group.name, prefix) {
+				continue
+			}
+			if _, ok := group.get("Image[$i]"); ok || strings.HasSuffix(group.name, "][$i") {
+				locked = true
+			}
+		}
+		if !locked {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func xfceLocked(root string) (bool, error) {
+	cfg, err := readXfconfChannel(filepath.Join(root, "etc", "xdg", "xfce4", "xfconf", "xfce-perchannel-xml", "xfce4-desktop.xml"), "xfce4-desktop")
+	if err != nil {
+		return false, err
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return false, err
+	}
+
+	listed := func(list string) bool {
+		for _, name := range strings.Split(list, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" || name == usr.Username {
+				return true
+			}
+		}
+		return false
+	}
+	return listed(cfg.Locked) && !listed(cfg.Unlocked), nil
+}
+
+func gsettingsLocked(schema, key string) (bool, error) {
+	output, err := exec.Command("gsettings", "writable", schema, key).Output()
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(string(output)) == "false", nil
+}
@@ -0,0 +1,101 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"os/user"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetPolicy(t *testing.T) {
+	root := t.TempDir()
+	copyDir(t, filepath.Join("testdata", "policy", "root"), root)
+	golden := filepath.Join("testdata", "policy", "golden")
+
+	for i := 0; i < 2; i++ {
+		err := SetPolicy(root, "/usr/share/backgrounds/company.jpg", Fit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareGolden(t, root, golden)
+	}
+}
+
+func TestKDELocked(t *testing.T) {
+	root := t.TempDir()
+	copyDir(t, filepath.Join("testdata", "policy", "root"), root)
+	alice := filepath.Join(root, "home", "alice")
+
+	locked, err := kdeLocked(root, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked {
+		t.Error("kdeLocked before SetPolicy = true")
+	}
+
+	err = SetPolicy(root, "/usr/share/backgrounds/company.jpg", Fit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a containment added after SetPolicy, e.g. for a new monitor, is not locked
+	carol := filepath.Join(root, "home", "carol")
+	err = writeIfChanged(filepath.Join(carol, ".config", "plasma-org.kde.plasma.desktop-appletsrc"),
+		[]byte("[Containments][1]\nplugin=org.kde.plasma.folder\nwallpaperplugin=org.kde.image\n\n[Containments][9]\nplugin=org.kde.plasma.folder\nwallpaperplugin=org.kde.image\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		home string
+		want bool
+	}{
+		{"every containment locked", alice, true},
+		{"new user gets containment 1", filepath.Join(root, "home", "bob"), true},
+		{"containment added later", carol, false},
+	}
+	for _, test := range tests {
+		locked, err := kdeLocked(root, test.home)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if locked != test.want {
+			t.Errorf("%s: kdeLocked = %v, want %v", test.name, locked, test.want)
+		}
+	}
+}
+
+func TestXFCELocked(t *testing.T) {
+	root := t.TempDir()
+
+	locked, err := xfceLocked(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked {
+		t.Error("xfceLocked without a policy = true")
+	}
+
+	err = SetPolicy(root, "/usr/share/backgrounds/company.jpg", Fit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the policy leaves root unlocked
+	want := usr.Username != "root"
+
+	locked, err = xfceLocked(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked != want {
+		t.Errorf("xfceLocked as %s = %v, want %v", usr.Username, locked, want)
+	}
+}
@@ -0,0 +1,210 @@
+package wallpaper
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GetResolved returns the image file the current wallpaper resolves to, along with the raw value returned by Get.
+// GNOME slideshow XML files are evaluated for the current time,
+// and images with several sizes are resolved to the one closest to the resolution of the largest monitor.
+func GetResolved() (string, string, error) {
+	raw, err := Default.Get()
+	if err != nil {
+		return "", raw, err
+	}
+
+	width, height := monitorSize()
+	resolved, err := resolveWallpaper(raw, time.Now(), width, height)
+	return resolved, raw, err
+}
+
+// monitorSize returns the size of the largest monitor, or of the screen if the monitors aren't known.
+// If neither is known, it returns sizes no image covers.
+func monitorSize() (int, int) {
+	monitors, err := Monitors()
+	if err == nil && len(monitors) > 0 {
+		largest := monitors[0]
+		for _, monitor := range monitors[1:] {
+			if monitor.Dx()*monitor.Dy() > largest.Dx()*largest.Dy() {
+				largest = monitor
+			}
+		}
+		return largest.Dx(), largest.Dy()
+	}
+
+	width, height, err := getScreenSize()
+	if err != nil || width == 0 || height == 0 {
+		return math.MaxInt32, math.MaxInt32
+	}
+	return width, height
+}
+
+func resolveWallpaper(path string, now time.Time, width, height int) (string, error) {
+	path = removeProtocol(strings.TrimPrefix(path, "image://"))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case info.IsDir():
+		return resolveKDEPackage(path, width, height)
+	case strings.EqualFold(filepath.Ext(path), ".xml"):
+		return resolveGNOMESlideshow(path, now, width, height)
+	default:
+		return path, nil
+	}
+}
+
+// resolveKDEPackage picks an image from contents/images, whose files are named after their resolution, e.g. 1920x1080.png.
+func resolveKDEPackage(dir string, width, height int) (string, error) {
+	images := filepath.Join(dir, "contents", "images")
+	files, err := os.ReadDir(images)
+	if err != nil {
+		return "", err
+	}
+
+	var sizes []imageSize
+	for _, file := range files {
+		var size imageSize
+		_, err := fmt.Sscanf(file.Name(), "%dx%d", &size.width, &size.height)
+		if err != nil || file.IsDir() {
+			continue
+		}
+		size.path = filepath.Join(images, file.Name())
+		sizes = append(sizes, size)
+	}
+	if len(sizes) == 0 {
+		return "", errors.New("kde wallpaper package has no images")
+	}
+
+	return closestSize(sizes, width, height), nil
+}
+
+type imageSize struct {
+	path          string
+	width, height int
+}
+
+// closestSize returns the smallest image that covers width by height, or the largest image if none do.
+func closestSize(sizes []imageSize, width, height int) string {
+	best := sizes[0]
+	for _, size := range sizes[1:] {
+		if size.covers(width, height) != best.covers(width, height) {
+			if size.covers(width, height) {
+				best = size
+			}
+		} else if size.covers(width, height) == (size.area() < best.area()) {
+			best = size
+		}
+	}
+	return best.path
+}
+
+func (size imageSize) covers(width, height int) bool {
+	return size.width >= width && size.height >= height
+}
+
+func (size imageSize) area() int {
+	return size.width * size.height
+}
+
+type slideshow struct {
+	StartTime struct {
+		Year   int `xml:"year"`
+		Month  int `xml:"month"`
+		Day    int `xml:"day"`
+		Hour   int `xml:"hour"`
+		Minute int `xml:"minute"`
+		Second int `xml:"second"`
+	} `xml:"starttime"`
+	// static and transition elements in document order
+	Items []struct {
+		XMLName  xml.Name
+		Duration float64         `xml:"duration"`
+		Files    []slideshowFile `xml:"file"`
+		From     string          `xml:"from"`
+		To       string          `xml:"to"`
+	} `xml:",any"`
+}
+
+type slideshowFile struct {
+	Path  string `xml:",chardata"`
+	Sizes []struct {
+		Width  int    `xml:"width,attr"`
+		Height int    `xml:"height,attr"`
+		Path   string `xml:",chardata"`
+	} `xml:"size"`
+}
+
+// resolveGNOMESlideshow finds the image shown at now by a GNOME timed slideshow.
+// During a transition, the image being faded to is returned once the transition is halfway done.
+func resolveGNOMESlideshow(file string, now time.Time, width, height int) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	var show slideshow
+	err = xml.Unmarshal(data, &show)
+	if err != nil {
+		return "", err
+	}
+
+	var total float64
+	for _, item := range show.Items {
+		total += item.Duration
+	}
+	if total <= 0 {
+		return "", errors.New("slideshow has no duration")
+	}
+
+	st := show.StartTime
+	start := time.Date(st.Year, time.Month(st.Month), st.Day, st.Hour, st.Minute, st.Second, 0, time.Local)
+	elapsed := math.Mod(now.Sub(start).Seconds(), total)
+	if elapsed < 0 {
+		elapsed += total
+	}
+
+	for _, item := range show.Items {
+		if elapsed >= item.Duration {
+			elapsed -= item.Duration
+			continue
+		}
+
+		switch item.XMLName.Local {
+		case "static":
+			if len(item.Files) == 0 {
+				return "", errors.New("slideshow image has no file")
+			}
+			return item.Files[0].resolve(width, height), nil
+		case "transition":
+			if elapsed < item.Duration/2 {
+				return strings.TrimSpace(item.From), nil
+			}
+			return strings.TrimSpace(item.To), nil
+		}
+	}
+
+	return "", errors.New("slideshow image not found")
+}
+
+func (file slideshowFile) resolve(width, height int) string {
+	if len(file.Sizes) == 0 {
+		return strings.TrimSpace(file.Path)
+	}
+
+	var sizes []imageSize
+	for _, size := range file.Sizes {
+		sizes = append(sizes, imageSize{strings.TrimSpace(size.Path), size.Width, size.Height})
+	}
+	return closestSize(sizes, width, height)
+}
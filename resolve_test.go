@@ -0,0 +1,169 @@
+package wallpaper
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoveProtocol(t *testing.T) {
+	tests := map[string]string{
+		"file:///home/u/My%20Pictures/x.jpg": "/home/u/My Pictures/x.jpg",
+		"file:///home/u/caf%C3%A9.png":       "/home/u/café.png",
+		"file:///home/u/100%.jpg":            "/home/u/100%.jpg",
+		"/home/u/100%20.jpg":                 "/home/u/100%20.jpg",
+	}
+	for input, want := range tests {
+		if got := removeProtocol(input); got != want {
+			t.Errorf("removeProtocol(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestClosestSize(t *testing.T) {
+	sizes := []imageSize{
+		{"3840x2160", 3840, 2160},
+		{"1280x720", 1280, 720},
+		{"1920x1080", 1920, 1080},
+	}
+	tests := []struct {
+		width, height int
+		want          string
+	}{
+		{1920, 1080, "1920x1080"},
+		{1366, 768, "1920x1080"},
+		{1024, 576, "1280x720"},
+		{1280, 1024, "1920x1080"},
+		{2560, 1440, "3840x2160"},
+		{5120, 2880, "3840x2160"},
+		{math.MaxInt32, math.MaxInt32, "3840x2160"},
+	}
+	for _, test := range tests {
+		if got := closestSize(sizes, test.width, test.height); got != test.want {
+			t.Errorf("closestSize for %dx%d = %s, want %s", test.width, test.height, got, test.want)
+		}
+	}
+}
+
+func TestResolveKDEPackage(t *testing.T) {
+	images := filepath.Join("testdata", "resolve", "Next", "contents", "images")
+	tests := []struct {
+		width, height int
+		want          string
+	}{
+		// the 800x600 directory isn't an image
+		{640, 480, "1280x720.png"},
+		{1920, 1200, "3840x2160.png"},
+		{1920, 1080, "1920x1080.png"},
+	}
+	for _, test := range tests {
+		got, err := resolveKDEPackage(filepath.Join("testdata", "resolve", "Next"), test.width, test.height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(images, test.want); got != want {
+			t.Errorf("%dx%d: got %s, want %s", test.width, test.height, got, want)
+		}
+	}
+
+	_, err := resolveKDEPackage(t.TempDir(), 1920, 1080)
+	if err == nil {
+		t.Error("empty package: no error")
+	}
+}
+
+func TestResolveGNOMESlideshow(t *testing.T) {
+	const (
+		day   = "/usr/share/backgrounds/day-1920x1080.jpg"
+		large = "/usr/share/backgrounds/day-3840x2160.jpg"
+		night = "/usr/share/backgrounds/night.jpg"
+	)
+	tests := []struct {
+		name  string
+		time  time.Time
+		width int
+		want  string
+	}{
+		{"day", time.Date(2024, 1, 1, 0, 30, 0, 0, time.Local), 1920, day},
+		{"day on a large monitor", time.Date(2024, 1, 1, 0, 30, 0, 0, time.Local), 2560, large},
+		{"early dusk", time.Date(2024, 1, 1, 1, 4, 0, 0, time.Local), 1920, day},
+		{"late dusk", time.Date(2024, 1, 1, 1, 6, 0, 0, time.Local), 1920, night},
+		{"night", time.Date(2024, 1, 1, 1, 30, 0, 0, time.Local), 1920, night},
+		{"late dawn", time.Date(2024, 1, 1, 1, 56, 0, 0, time.Local), 1920, day},
+		{"next cycle", time.Date(2024, 1, 3, 2, 30, 0, 0, time.Local), 1920, day},
+		{"before the start", time.Date(2023, 12, 31, 23, 30, 0, 0, time.Local), 1920, night},
+	}
+	for _, test := range tests {
+		got, err := resolveGNOMESlideshow(filepath.Join("testdata", "resolve", "slideshow.xml"), test.time, test.width, test.width*9/16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestResolveGNOMESlideshowErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"no duration": "<background><static><file>a.jpg</file></static></background>",
+		"no file":     "<background><static><duration>60</duration></static></background>",
+		"malformed":   "<background><static>",
+	}
+	for name, xml := range tests {
+		file := filepath.Join(dir, strings.ReplaceAll(name, " ", "-")+".xml")
+		err := os.WriteFile(file, []byte(xml), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = resolveGNOMESlideshow(file, time.Now(), 1920, 1080)
+		if err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestGetResolved(t *testing.T) {
+	// without monitors, the largest image wins
+	setEnv(t, "DISPLAY", "")
+	setEnv(t, "PATH", t.TempDir())
+
+	dir := filepath.Join(t.TempDir(), "My Wallpapers")
+	for _, name := range []string{"contents/screenshot.png", "contents/images/1280x720.png", "contents/images/3840x2160.png"} {
+		file := filepath.Join(dir, "Next", filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(file), 0755)
+		if err == nil {
+			err = os.WriteFile(file, nil, 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := map[string]string{
+		"file://" + strings.ReplaceAll(dir, " ", "%20") + "/Next":                              filepath.Join(dir, "Next", "contents", "images", "3840x2160.png"),
+		"image://" + dir + "/Next":                                                             filepath.Join(dir, "Next", "contents", "images", "3840x2160.png"),
+		"file://" + strings.ReplaceAll(dir, " ", "%20") + "/Next/contents/images/1280x720.png": filepath.Join(dir, "Next", "contents", "images", "1280x720.png"),
+		dir + "/Next/contents/screenshot.png":                                                  filepath.Join(dir, "Next", "contents", "screenshot.png"),
+	}
+	for raw, want := range tests {
+		useTestBackend(t, raw)
+		resolved, gotRaw, err := GetResolved()
+		if err != nil {
+			t.Errorf("%s: %v", raw, err)
+			continue
+		}
+		if resolved != want || gotRaw != raw {
+			t.Errorf("GetResolved() = %q, %q, want %q, %q", resolved, gotRaw, want, raw)
+		}
+	}
+
+	useTestBackend(t, "file://"+dir+"/missing.jpg")
+	_, _, err := GetResolved()
+	if err == nil {
+		t.Error("missing file: no error")
+	}
+}
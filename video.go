@@ -0,0 +1,365 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Video is a looping video wallpaper played by RunVideo.
+type Video struct {
+	File string
+	// PauseOnBattery pauses playback while no AC adapter is online.
+	PauseOnBattery bool
+	// PauseWhenFullscreen pauses playback while the active X11 window is fullscreen.
+	PauseWhenFullscreen bool
+	// Pause is polled along with the other conditions and pauses playback while it returns true.
+	Pause func() bool
+}
+
+var (
+	// videoPollInterval is how often the pause conditions are checked.
+	videoPollInterval = 5 * time.Second
+	// videoRestartDelay is the delay before the first restart of crashed players, doubling up to a minute.
+	videoRestartDelay = time.Second
+	// powerSupplyDir is where the kernel lists the power supplies checked by PauseOnBattery.
+	powerSupplyDir = "/sys/class/power_supply"
+)
+
+// videoState is shared with other processes through the cache directory,
+// so that SetFromFile and Get know about a video played by another program.
+type videoState struct {
+	File       string `json:"file"`
+	Supervisor int    `json:"supervisor"`
+	Players    []int  `json:"players"`
+}
+
+// RunVideo plays video as the wallpaper until ctx is canceled or a static wallpaper is set with SetFromFile.
+// It uses mpvpaper on wlroots-based Wayland compositors and an xwinwrap window running mpv per monitor on X11.
+// Players that exit on their own are restarted with a growing delay.
+func RunVideo(ctx context.Context, video Video) error {
+	file, err := filepath.Abs(video.File)
+	if err != nil {
+		return err
+	}
+
+	// replace a video that is already playing
+	err = stopVideo()
+	if err != nil {
+		return err
+	}
+
+	delay := videoRestartDelay
+	for {
+		started := time.Now()
+		players, err := startVideo(file)
+		if err != nil {
+			return err
+		}
+
+		err = writeVideoState(videoState{file, os.Getpid(), playerPIDs(players)})
+		if err != nil {
+			stopPlayers(playerPIDs(players))
+			return err
+		}
+
+		stopped, err := superviseVideo(ctx, video, players)
+		if stopped || err != nil {
+			return err
+		}
+
+		// a player crashed
+		if time.Since(started) > time.Minute {
+			delay = videoRestartDelay
+		}
+		select {
+		case <-ctx.Done():
+			return removeVideoState(players)
+		case <-time.After(delay):
+		}
+		// SetFromFile may have stopped the video while waiting
+		if !ownsVideoState(players) {
+			return nil
+		}
+		if delay < time.Minute {
+			delay *= 2
+		}
+	}
+}
+
+func startVideo(file string) ([]*exec.Cmd, error) {
+	var commands [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		commands = append(commands, []string{"mpvpaper", "-o", "no-audio loop panscan=1.0", "*", file})
+	} else {
+		c, err := dialX11()
+		if err != nil {
+			return nil, err
+		}
+		monitors, err := c.monitors()
+		c.close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, monitor := range monitors {
+			geometry := fmt.Sprintf("%dx%d+%d+%d", monitor.Dx(), monitor.Dy(), monitor.Min.X, monitor.Min.Y)
+			commands = append(commands, []string{"xwinwrap", "-g", geometry, "-ni", "-s", "-nf", "-b", "-un", "-ov", "--",
+				"mpv", "-wid", "WID", "--loop", "--no-audio", "--no-osc", "--no-input-default-bindings", "--panscan=1.0", file})
+		}
+	}
+
+	var players []*exec.Cmd
+	for _, args := range commands {
+		cmd := exec.Command(args[0], args[1:]...)
+		// a process group per player, so that signals also reach mpv started by xwinwrap
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		err := cmd.Start()
+		if err != nil {
+			stopPlayers(playerPIDs(players))
+			return nil, err
+		}
+		players = append(players, cmd)
+	}
+	return players, nil
+}
+
+// superviseVideo waits until ctx is canceled or a player exits, pausing and resuming players in the meantime.
+// It reports whether the video was stopped rather than crashed.
+func superviseVideo(ctx context.Context, video Video, players []*exec.Cmd) (bool, error) {
+	exited := make(chan struct{}, len(players))
+	for _, player := range players {
+		go func(player *exec.Cmd) {
+			player.Wait()
+			exited <- struct{}{}
+		}(player)
+	}
+
+	paused := false
+	for {
+		select {
+		case <-ctx.Done():
+			stopPlayers(playerPIDs(players))
+			return true, removeVideoState(players)
+		case <-exited:
+			stopPlayers(playerPIDs(players))
+			// stopVideo removes the state before killing the players
+			return !ownsVideoState(players), nil
+		case <-time.After(videoPollInterval):
+			pause := video.paused()
+			if pause != paused {
+				signal := syscall.SIGCONT
+				if pause {
+					signal = syscall.SIGSTOP
+				}
+				for _, pid := range playerPIDs(players) {
+					syscall.Kill(-pid, signal)
+				}
+				paused = pause
+			}
+		}
+	}
+}
+
+func (video Video) paused() bool {
+	if video.PauseOnBattery && onBattery() {
+		return true
+	}
+	if video.PauseWhenFullscreen {
+		fullscreen, err := x11Fullscreen()
+		if err == nil && fullscreen {
+			return true
+		}
+	}
+	return video.Pause != nil && video.Pause()
+}
+
+// onBattery reports whether the system has an AC adapter and none of them is online.
+func onBattery() bool {
+	supplies, _ := filepath.Glob(filepath.Join(powerSupplyDir, "*"))
+	mains := false
+	for _, supply := range supplies {
+		typ, err := os.ReadFile(filepath.Join(supply, "type"))
+		if err != nil || strings.TrimSpace(string(typ)) != "Mains" {
+			continue
+		}
+
+		mains = true
+		online, err := os.ReadFile(filepath.Join(supply, "online"))
+		if err == nil && strings.TrimSpace(string(online)) == "1" {
+			return false
+		}
+	}
+	return mains
+}
+
+// x11Fullscreen reports whether the active window has _NET_WM_STATE_FULLSCREEN set.
+func x11Fullscreen() (bool, error) {
+	c, err := dialX11()
+	if err != nil {
+		return false, err
+	}
+	defer c.close()
+
+	activeWindow, err := c.internAtom("_NET_ACTIVE_WINDOW")
+	if err != nil {
+		return false, err
+	}
+	_, value, err := c.getProperty(c.screen.root, activeWindow)
+	if err != nil || len(value) != 4 {
+		return false, err
+	}
+	window := binary.LittleEndian.Uint32(value)
+
+	state, err := c.internAtom("_NET_WM_STATE")
+	if err != nil {
+		return false, err
+	}
+	fullscreen, err := c.internAtom("_NET_WM_STATE_FULLSCREEN")
+	if err != nil {
+		return false, err
+	}
+	_, value, err = c.getProperty(window, state)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i+4 <= len(value); i += 4 {
+		if binary.LittleEndian.Uint32(value[i:]) == fullscreen {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func playerPIDs(players []*exec.Cmd) []int {
+	var pids []int
+	for _, player := range players {
+		pids = append(pids, player.Process.Pid)
+	}
+	return pids
+}
+
+func stopPlayers(pids []int) {
+	for _, pid := range pids {
+		// paused players only handle SIGTERM once resumed
+		syscall.Kill(-pid, syscall.SIGCONT)
+		syscall.Kill(-pid, syscall.SIGTERM)
+	}
+}
+
+func videoStateFile() (string, error) {
+	cacheDir, err := getCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "wallpaper-video.json"), nil
+}
+
+func readVideoState() (videoState, error) {
+	var state videoState
+	name, err := videoStateFile()
+	if err != nil {
+		return state, err
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return state, err
+	}
+	return state, json.Unmarshal(data, &state)
+}
+
+func writeVideoState(state videoState) error {
+	name, err := videoStateFile()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0644)
+}
+
+// ownsVideoState reports whether the state still describes players, i.e. the video wasn't stopped or replaced.
+func ownsVideoState(players []*exec.Cmd) bool {
+	state, err := readVideoState()
+	return err == nil && state.Supervisor == os.Getpid() && len(state.Players) > 0 && state.Players[0] == players[0].Process.Pid
+}
+
+func removeVideoState(players []*exec.Cmd) error {
+	if !ownsVideoState(players) {
+		return nil
+	}
+
+	name, err := videoStateFile()
+	if err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+// stopVideo stops the video started by RunVideo in any process.
+func stopVideo() error {
+	state, err := readVideoState()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// removed first, so that the supervisor doesn't restart the players
+	name, err := videoStateFile()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(name)
+	if err != nil {
+		return err
+	}
+
+	// a state left behind by a killed supervisor or before a reboot may name reused PIDs
+	if state.Supervisor == 0 || syscall.Kill(state.Supervisor, 0) != nil {
+		return nil
+	}
+	var players []int
+	for _, pid := range state.Players {
+		if isPlayer(pid) {
+			players = append(players, pid)
+		}
+	}
+	stopPlayers(players)
+	return nil
+}
+
+// isPlayer reports whether pid is a running mpvpaper or xwinwrap process.
+func isPlayer(pid int) bool {
+	comm, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return false
+	}
+	name := strings.TrimSpace(string(comm))
+	return name == "mpvpaper" || name == "xwinwrap"
+}
+
+// runningVideo returns the video played by RunVideo, if its supervisor is still running.
+func runningVideo() (string, bool) {
+	state, err := readVideoState()
+	if err != nil || state.Supervisor == 0 {
+		return "", false
+	}
+	return state.File, syscall.Kill(state.Supervisor, 0) == nil
+}
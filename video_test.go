@@ -0,0 +1,279 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// useStubPlayers puts an mpvpaper that runs script on PATH and makes startVideo pick it.
+// The script can log to $VIDEO_LOG, whose name is returned.
+func useStubPlayers(t *testing.T, script string) string {
+	t.Helper()
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "mpvpaper"), []byte("#!/bin/sh\n"+script), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setEnv(t, "PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	setEnv(t, "WAYLAND_DISPLAY", "wayland-test")
+
+	log := filepath.Join(dir, "log")
+	setEnv(t, "VIDEO_LOG", log)
+	return log
+}
+
+func setVideoTiming(t *testing.T, poll, restart time.Duration) {
+	oldPoll, oldRestart := videoPollInterval, videoRestartDelay
+	videoPollInterval, videoRestartDelay = poll, restart
+	t.Cleanup(func() { videoPollInterval, videoRestartDelay = oldPoll, oldRestart })
+}
+
+func startRunVideo(ctx context.Context, video Video) chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- RunVideo(ctx, video)
+	}()
+	return done
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); !condition(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func readLines(name string) []string {
+	data, _ := os.ReadFile(name)
+	return strings.Fields(string(data))
+}
+
+// processState returns the state letter of pid from /proc, such as 'S', 'T' or 'Z', or 0 if it doesn't exist.
+func processState(pid int) byte {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	i := strings.LastIndexByte(string(stat), ')')
+	if err != nil || i == -1 || i+2 >= len(stat) {
+		return 0
+	}
+	return stat[i+2]
+}
+
+func TestRunVideoRestarts(t *testing.T) {
+	useTempCache(t)
+	// the player crashes right away
+	log := useStubPlayers(t, `date +%s%N >> "$VIDEO_LOG"`)
+	setVideoTiming(t, time.Hour, 20*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startRunVideo(ctx, Video{File: "video.mp4"})
+	waitFor(t, "5 starts", func() bool { return len(readLines(log)) >= 5 })
+	cancel()
+	err := <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	starts := readLines(log)
+	for i := 1; i < 5; i++ {
+		previous, _ := strconv.ParseInt(starts[i-1], 10, 64)
+		current, _ := strconv.ParseInt(starts[i], 10, 64)
+		want := videoRestartDelay << (i - 1)
+		if delay := time.Duration(current - previous); delay < want {
+			t.Errorf("restart %d after %v, want at least %v", i, delay, want)
+		}
+	}
+
+	_, err = readVideoState()
+	if !os.IsNotExist(err) {
+		t.Errorf("video state left behind: %v", err)
+	}
+}
+
+func TestRunVideoPauseAndStop(t *testing.T) {
+	useTempCache(t)
+	log := useStubPlayers(t, "echo $$ >> \"$VIDEO_LOG\"\nsleep 60 &\nwait\n")
+	setVideoTiming(t, 10*time.Millisecond, time.Hour)
+
+	file := filepath.Join(t.TempDir(), "video.mp4")
+	var pause int32
+	done := startRunVideo(context.Background(), Video{
+		File:  file,
+		Pause: func() bool { return atomic.LoadInt32(&pause) == 1 },
+	})
+
+	var state videoState
+	waitFor(t, "the player", func() bool {
+		var err error
+		state, err = readVideoState()
+		return err == nil
+	})
+	if len(state.Players) != 1 || state.Supervisor != os.Getpid() {
+		t.Fatalf("state = %+v", state)
+	}
+	player := state.Players[0]
+	got, err := Get()
+	if err != nil || got != file {
+		t.Errorf("Get() = %q, %v, want the video", got, err)
+	}
+
+	atomic.StoreInt32(&pause, 1)
+	waitFor(t, "SIGSTOP", func() bool { return processState(player) == 'T' })
+	atomic.StoreInt32(&pause, 0)
+	waitFor(t, "SIGCONT", func() bool { return processState(player) != 'T' })
+
+	// setting a static wallpaper stops the video for good
+	old := Desktop
+	Desktop = "MATE"
+	t.Cleanup(func() { Desktop = old })
+	setDesktopSession(t, "")
+	err = os.WriteFile(filepath.Join(filepath.Dir(log), "dconf"), []byte("#!/bin/sh\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SetFromFile("/usr/share/backgrounds/static.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("RunVideo still running after SetFromFile")
+	}
+	if processState(player) != 0 {
+		t.Errorf("player %d still running", player)
+	}
+	if starts := readLines(log); len(starts) != 1 {
+		t.Errorf("player started %d times", len(starts))
+	}
+}
+
+// A video stopped while waiting to restart crashed players must stay stopped.
+func TestRunVideoStopDuringRestartDelay(t *testing.T) {
+	useTempCache(t)
+	log := useStubPlayers(t, `echo $$ >> "$VIDEO_LOG"`)
+	setVideoTiming(t, time.Hour, 300*time.Millisecond)
+
+	done := startRunVideo(context.Background(), Video{File: "video.mp4"})
+	waitFor(t, "the player", func() bool { return len(readLines(log)) == 1 })
+	// let the supervisor notice the crash and start waiting
+	time.Sleep(50 * time.Millisecond)
+	err := stopVideo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("RunVideo still running after stopVideo")
+	}
+	if starts := readLines(log); len(starts) != 1 {
+		t.Errorf("player started %d times", len(starts))
+	}
+	if file, running := runningVideo(); running {
+		t.Errorf("runningVideo() = %q, true", file)
+	}
+}
+
+func TestOnBattery(t *testing.T) {
+	tests := map[string]struct {
+		supplies map[string][2]string
+		want     bool
+	}{
+		"desktop without supplies": {nil, false},
+		"battery only":             {map[string][2]string{"BAT0": {"Battery", ""}}, false},
+		"unplugged":                {map[string][2]string{"AC": {"Mains", "0"}, "BAT0": {"Battery", ""}}, true},
+		"plugged in":               {map[string][2]string{"AC": {"Mains", "1"}, "BAT0": {"Battery", ""}}, false},
+		"one of two adapters":      {map[string][2]string{"AC0": {"Mains", "0"}, "AC1": {"Mains", "1"}}, false},
+		"unreadable online":        {map[string][2]string{"AC": {"Mains", ""}}, true},
+	}
+	old := powerSupplyDir
+	t.Cleanup(func() { powerSupplyDir = old })
+
+	for name, test := range tests {
+		powerSupplyDir = t.TempDir()
+		for supply, values := range test.supplies {
+			dir := filepath.Join(powerSupplyDir, supply)
+			err := os.Mkdir(dir, 0755)
+			if err == nil {
+				err = os.WriteFile(filepath.Join(dir, "type"), []byte(values[0]+"\n"), 0644)
+			}
+			if err == nil && values[1] != "" {
+				err = os.WriteFile(filepath.Join(dir, "online"), []byte(values[1]+"\n"), 0644)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := onBattery(); got != test.want {
+			t.Errorf("%s: onBattery() = %v, want %v", name, got, test.want)
+		}
+	}
+}
+
+func TestStopVideoStaleState(t *testing.T) {
+	useTempCache(t)
+
+	// a process that took over a PID recorded in the state
+	other := exec.Command("sleep", "60")
+	other.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	err := other.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		other.Process.Kill()
+		other.Wait()
+	}()
+
+	exited := exec.Command("true")
+	err = exited.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]int{
+		"dead supervisor": exited.Process.Pid,
+		"not a player":    os.Getpid(),
+	}
+	for name, supervisor := range tests {
+		err = writeVideoState(videoState{"video.mp4", supervisor, []int{other.Process.Pid}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = stopVideo()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		_, err = readVideoState()
+		if !os.IsNotExist(err) {
+			t.Errorf("%s: state not removed: %v", name, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if state := processState(other.Process.Pid); state == 0 || state == 'Z' {
+			t.Errorf("%s: unrelated process killed", name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package wallpapertest implements support for testing implementations of wallpaper.Backend.
+package wallpapertest
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ktkv419/wallpaper"
+)
+
+// TestBackend tests a wallpaper.Backend implementation.
+// It sets each of files as the wallpaper and checks that Get returns it,
+// then sets every mode and checks that ModeMatches reports it.
+// At least two files should be given so that a change can be told apart from a backend that ignores SetFromFile.
+// The original wallpaper and mode are restored at the end if they could be read.
+//
+// Typical usage inside a test is:
+//
+//	if err := wallpapertest.TestBackend(backend, "testdata/a.jpg", "testdata/b.jpg"); err != nil {
+//		t.Fatal(err)
+//	}
+func TestBackend(backend wallpaper.Backend, files ...string) error {
+	if len(files) == 0 {
+		return errors.New("testing backend: no files given")
+	}
+
+	var errs []string
+	report := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	original, err := backend.Get()
+	if err != nil {
+		report("Get: %v", err)
+	}
+	originalMode, haveMode := currentMode(backend)
+
+	for _, file := range files {
+		file, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+
+		// setting the same file twice must be harmless
+		for i := 0; i < 2; i++ {
+			err = backend.SetFromFile(file)
+			if err != nil {
+				report("SetFromFile(%q): %v", file, err)
+				continue
+			}
+
+			current, err := backend.Get()
+			if err != nil {
+				report("Get after SetFromFile(%q): %v", file, err)
+			} else if !samePath(current, file) {
+				report("Get after SetFromFile(%q) = %q", file, current)
+			}
+		}
+	}
+
+	for _, mode := range modes {
+		name, _ := mode.MarshalText()
+		err = backend.SetMode(mode)
+		if err != nil {
+			report("SetMode(%s): %v", name, err)
+			continue
+		}
+
+		matches, err := backend.ModeMatches(mode)
+		if err != nil {
+			report("ModeMatches(%s) after SetMode: %v", name, err)
+		} else if !matches {
+			report("ModeMatches(%s) after SetMode = false", name)
+		}
+	}
+
+	// the mode must not change the image
+	last, _ := filepath.Abs(files[len(files)-1])
+	current, err := backend.Get()
+	if err == nil && !samePath(current, last) {
+		report("Get after SetMode = %q, want %q", current, last)
+	}
+
+	if haveMode {
+		err = backend.SetMode(originalMode)
+		if err != nil {
+			name, _ := originalMode.MarshalText()
+			report("restoring mode %s: %v", name, err)
+		}
+	}
+	if original != "" {
+		err = backend.SetFromFile(original)
+		if err != nil {
+			report("restoring %q: %v", original, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New("TestBackend found errors:\n" + strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+var modes = []wallpaper.Mode{wallpaper.Center, wallpaper.Crop, wallpaper.Fit, wallpaper.Span, wallpaper.Stretch, wallpaper.Tile}
+
+// currentMode returns the first mode the backend reports as current.
+// It returns false if none matches, for example when the desktop uses a mode this package doesn't know.
+func currentMode(backend wallpaper.Backend) (wallpaper.Mode, bool) {
+	for _, mode := range modes {
+		matches, err := backend.ModeMatches(mode)
+		if err != nil {
+			return 0, false
+		}
+		if matches {
+			return mode, true
+		}
+	}
+	return 0, false
+}
+
+// samePath compares paths, ignoring a file:// prefix some desktops report.
+func samePath(got, want string) bool {
+	return filepath.Clean(strings.TrimPrefix(got, "file://")) == filepath.Clean(want)
+}
@@ -4,6 +4,7 @@
 package wallpaper
 
 import (
+	"image"
 	"log"
 	"os"
 	"os/exec"
@@ -26,10 +27,17 @@ const (
 	spifSendChange    = 0x02
 )
 
+// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getsystemmetrics
+const (
+	smCXScreen = 0
+	smCYScreen = 1
+)
+
 // https://msdn.microsoft.com/en-us/library/windows/desktop/ms724947.aspx
 var (
 	user32               = syscall.NewLazyDLL("user32.dll")
 	systemParametersInfo = user32.NewProc("SystemParametersInfoW")
+	getSystemMetrics     = user32.NewProc("GetSystemMetrics")
 )
 
 // Checks if the script is running as Administrator
@@ -179,46 +187,114 @@ func SetMode(mode Mode) error {
 	}
 	defer key.Close()
 
+	style, tile := mode.getWindowsStrings()
+	err = key.SetStringValue("TileWallpaper", tile)
+	if err != nil {
+		return err
+	}
+
+	err = key.SetStringValue("WallpaperStyle", style)
+	if err != nil {
+		return err
+	}
+
+	// updates wallpaper
+	path, err := Get()
+	if err != nil {
+		return err
+	}
+
+	return SetFromFile(path)
+}
+
+// modeMatches reports whether the desktop already uses mode.
+func modeMatches(mode Mode) (bool, error) {
+	key, err := registry.OpenKey(registry.CURRENT_USER, "Control Panel\\Desktop", registry.QUERY_VALUE)
+	if err != nil {
+		return false, err
+	}
+	defer key.Close()
+
+	style, _, err := key.GetStringValue("WallpaperStyle")
+	if err != nil {
+		return false, err
+	}
+	tile, _, err := key.GetStringValue("TileWallpaper")
+	if err != nil {
+		return false, err
+	}
+
+	expectedStyle, expectedTile := mode.getWindowsStrings()
+	return style == expectedStyle && tile == expectedTile, nil
+}
+
+// getWindowsStrings returns the WallpaperStyle and TileWallpaper registry values for mode.
+func (mode Mode) getWindowsStrings() (string, string) {
 	var tile string
 	if mode == Tile {
 		tile = "1"
 	} else {
 		tile = "0"
 	}
-	err = key.SetStringValue("TileWallpaper", tile)
-	if err != nil {
-		return err
-	}
 
-	var style string
 	switch mode {
 	case Center, Tile:
-		style = "0"
+		return "0", tile
 	case Fit:
-		style = "6"
+		return "6", tile
 	case Span:
-		style = "22"
+		return "22", tile
 	case Stretch:
-		style = "2"
+		return "2", tile
 	case Crop:
-		style = "10"
+		return "10", tile
 	default:
 		panic("invalid wallpaper mode")
 	}
-	err = key.SetStringValue("WallpaperStyle", style)
-	if err != nil {
-		return err
-	}
+}
 
-	// updates wallpaper
-	path, err := Get()
+// Monitors returns ErrUnsupportedDE since monitor geometry is only available on Linux.
+func Monitors() ([]image.Rectangle, error) {
+	return nil, ErrUnsupportedDE
+}
+
+// getDark returns ErrUnsupportedDE since there is no separate dark wallpaper.
+func getDark() (string, error) {
+	return "", ErrUnsupportedDE
+}
+
+// setDark returns ErrUnsupportedDE since there is no separate dark wallpaper.
+func setDark(file string) error {
+	return ErrUnsupportedDE
+}
+
+// getLockScreen returns the lock screen image set by setLockscreen.
+func getLockScreen() (string, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`, registry.QUERY_VALUE)
 	if err != nil {
-		return err
+		return "", err
 	}
+	defer key.Close()
 
-	return SetFromFile(path)
+	path, _, err := key.GetStringValue("LockScreenImagePath")
+	return path, err
+}
+
+// setLockScreen sets the lock screen image for all users, which asks for elevation.
+func setLockScreen(file string) error {
+	return setLockscreen(file)
 }
 
 func getCacheDir() (string, error) {
+	if CacheDir != "" {
+		return CacheDir, nil
+	}
 	return os.TempDir(), nil
 }
+
+// getScreenSize returns the size of the primary monitor.
+func getScreenSize() (int, int, error) {
+	width, _, _ := getSystemMetrics.Call(uintptr(smCXScreen))
+	height, _, _ := getSystemMetrics.Call(uintptr(smCYScreen))
+	return int(width), int(height), nil
+}
@@ -0,0 +1,179 @@
+package wallpaper
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"time"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// City is a place marked on a DayNightMap with its local time.
+type City struct {
+	Name      string
+	Latitude  float64
+	Longitude float64
+	// TimeZone is an IANA time zone name such as "Europe/Berlin".
+	TimeZone string
+}
+
+//go:generate go run worldmap_gen.go
+//go:embed worldmap.png
+var worldMap []byte
+
+// DayNightMap shades the night side of an equirectangular world map.
+type DayNightMap struct {
+	// Map is the path to an equirectangular image spanning 180°W to 180°E and 90°N to 90°S.
+	// If empty, a simple embedded map of the coastlines is used.
+	Map string
+	// Time is the time to show. If zero, the current time is used.
+	Time   time.Time
+	Cities []City
+}
+
+// Generate implements Generator.
+func (m DayNightMap) Generate(width, height int) (image.Image, error) {
+	var base image.Image
+	var err error
+	if m.Map == "" {
+		base, err = png.Decode(bytes.NewReader(worldMap))
+	} else {
+		base, err = decodeImage(m.Map)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+
+	// fit the 2:1 map inside the screen
+	mapWidth, mapHeight := width, width/2
+	if mapHeight > height {
+		mapWidth, mapHeight = height*2, height
+	}
+	area := image.Rect(0, 0, mapWidth, mapHeight).Add(image.Pt((width-mapWidth)/2, (height-mapHeight)/2))
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.Black, image.Point{}, draw.Src)
+	xdraw.CatmullRom.Scale(img, area, base, base.Bounds(), draw.Src, nil)
+
+	sunLat, sunLon := subsolarPoint(m.Time)
+	sinSunLat, cosSunLat := math.Sincos(sunLat * math.Pi / 180)
+	// civil twilight ends when the sun is 6° below the horizon
+	twilight := math.Sin(6 * math.Pi / 180)
+	mapPixels(img.SubImage(area).(*image.RGBA), func(x, y int, r, g, b float64) (float64, float64, float64) {
+		lat := 90 - (float64(y-area.Min.Y)+0.5)/float64(mapHeight)*180
+		lon := (float64(x-area.Min.X)+0.5)/float64(mapWidth)*360 - 180
+		sinLat, cosLat := math.Sincos(lat * math.Pi / 180)
+		altitude := sinLat*sinSunLat + cosLat*cosSunLat*math.Cos((lon-sunLon)*math.Pi/180)
+		k := 1 - 0.65*math.Max(0, math.Min(1, (twilight-altitude)/(2*twilight)))
+		return r * k, g * k, b * k
+	})
+
+	if len(m.Cities) == 0 {
+		return img, nil
+	}
+
+	parsed, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{
+		Size:    float64(mapHeight) / 50,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	radius := mapHeight / 200
+	if radius < 2 {
+		radius = 2
+	}
+	for _, city := range m.Cities {
+		location, err := time.LoadLocation(city.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+
+		x := area.Min.X + int((city.Longitude+180)/360*float64(mapWidth))
+		y := area.Min.Y + int((90-city.Latitude)/180*float64(mapHeight))
+		draw.Draw(img, image.Rect(x-radius, y-radius, x+radius, y+radius), image.NewUniform(color.RGBA{255, 80, 60, 255}), image.Point{}, draw.Src)
+
+		drawer := font.Drawer{Dst: img, Src: image.White, Face: face}
+		drawer.Dot = fixed.P(x+2*radius, y+radius)
+		drawer.DrawString(city.Name + " " + m.Time.In(location).Format("15:04"))
+	}
+
+	return img, nil
+}
+
+// subsolarPoint returns the latitude and longitude in degrees where the sun is directly overhead.
+// https://en.wikipedia.org/wiki/Position_of_the_Sun
+func subsolarPoint(t time.Time) (float64, float64) {
+	// days since J2000.0
+	n := float64(t.Unix())/86400 + 2440587.5 - 2451545.0
+	rad := math.Pi / 180
+
+	meanLongitude := 280.460 + 0.9856474*n
+	meanAnomaly := (357.528 + 0.9856003*n) * rad
+	eclipticLongitude := (meanLongitude + 1.915*math.Sin(meanAnomaly) + 0.020*math.Sin(2*meanAnomaly)) * rad
+	obliquity := (23.439 - 0.0000004*n) * rad
+
+	declination := math.Asin(math.Sin(obliquity) * math.Sin(eclipticLongitude))
+	rightAscension := math.Atan2(math.Cos(obliquity)*math.Sin(eclipticLongitude), math.Cos(eclipticLongitude))
+	siderealTime := 280.46061837 + 360.98564736629*n
+
+	longitude := math.Mod(rightAscension/rad-siderealTime, 360)
+	if longitude < -180 {
+		longitude += 360
+	} else if longitude > 180 {
+		longitude -= 360
+	}
+	return declination / rad, longitude
+}
+
+// RunDayNightMap renders the map for the current time and sets it every interval until ctx is done.
+// Intervals shorter than a second are raised to a second.
+// The renders alternate between two files in the cache directory rather than adding one per interval.
+func RunDayNightMap(ctx context.Context, m DayNightMap, interval time.Duration, effects ...Effect) error {
+	if interval < minRefreshInterval {
+		interval = minRefreshInterval
+	}
+	for i := 0; ; i++ {
+		m.Time = time.Now().Truncate(time.Minute)
+		img, err := m.Generate(generatorSize())
+		if err != nil {
+			return err
+		}
+
+		rgba := toRGBA(img)
+		for _, effect := range effects {
+			rgba = effect.Apply(rgba)
+		}
+
+		err = setRotating("worldmap", i, rgba)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,185 @@
+//go:build ignore
+// +build ignore
+
+// This program draws worldmap.png, the base map embedded for DayNightMap.
+// The coastlines are a coarse outline traced by hand from public domain maps,
+// in degrees of longitude and latitude.
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"math"
+	"os"
+
+	"golang.org/x/image/vector"
+)
+
+const width, height = 2048, 1024
+
+var (
+	ocean = color.RGBA{18, 42, 84, 255}
+	land  = color.RGBA{86, 118, 62, 255}
+	dry   = color.RGBA{170, 146, 98, 255}
+	ice   = color.RGBA{222, 228, 234, 255}
+)
+
+// land is drawn first, then the inland seas on top.
+var landPolygons = [][][2]float64{
+	// North America
+	{{-165, 68.8}, {-156.5, 71.3}, {-141, 69.6}, {-130, 70}, {-117, 68.5}, {-108, 68}, {-97, 68}, {-94, 71.3}, {-89, 68.5}, {-82, 69}, {-86, 66}, {-88, 64.2}, {-94, 61}, {-93, 58.7}, {-88, 56.5}, {-82, 55}, {-82, 52.5}, {-79, 51.5}, {-78.5, 55}, {-77, 60.5}, {-78, 62.5}, {-73, 62}, {-69.5, 61}, {-65, 60}, {-64.5, 60.3}, {-61.5, 56}, {-57, 53}, {-55.7, 52}, {-60, 50.2}, {-66.5, 50}, {-70, 47}, {-64.3, 48.8}, {-65, 47}, {-61, 45.8}, {-66, 43.5}, {-70, 43.7}, {-70, 41.7}, {-74, 40.5}, {-75.5, 38}, {-76, 36.9}, {-75.5, 35.2}, {-78, 33.8}, {-81, 31.5}, {-80, 27}, {-80.4, 25.2}, {-81.8, 26.5}, {-83, 29.1}, {-84.3, 30}, {-86.5, 30.4}, {-89.4, 30.2}, {-89.2, 29.1}, {-94, 29.6}, {-97.2, 27.8}, {-97.4, 25}, {-97.8, 22}, {-96.2, 19.3}, {-94.5, 18.2}, {-91, 18.7}, {-90.4, 21}, {-87, 21.5}, {-87.5, 18.5}, {-88.3, 16}, {-84, 15.8}, {-83.2, 15}, {-83.5, 11.5}, {-81.5, 9}, {-79.5, 9.5}, {-77.3, 8.7}, {-78, 7.5}, {-80.5, 7.3}, {-81.7, 8.2}, {-85.7, 9.9}, {-85.7, 11}, {-87.5, 13}, {-91.5, 14}, {-94.5, 16.2}, {-96.5, 15.7}, {-99.5, 16.7}, {-104.2, 19.1}, {-105.7, 20.5}, {-105.2, 21.8}, {-106.4, 23.2}, {-109, 26}, {-112.5, 29.9}, {-114.7, 31.7}, {-112.8, 28.3}, {-110.5, 24.3}, {-109.5, 23.1}, {-110.3, 23.5}, {-112.1, 24.8}, {-114.2, 27.7}, {-115.7, 29.8}, {-117.1, 32.5}, {-118.5, 34}, {-120.6, 34.6}, {-122.5, 37.7}, {-124.2, 40.4}, {-124.5, 43}, {-124, 46.3}, {-124.7, 48.4}, {-123, 49}, {-127.5, 50.7}, {-130.5, 54.5}, {-133, 57}, {-136, 58.3}, {-140, 59.8}, {-146, 60.8}, {-151.5, 59.2}, {-154, 57.6}, {-156, 57.5}, {-162, 55}, {-164.8, 54.5}, {-160, 56.5}, {-157.5, 58.7}, {-162, 58.7}, {-165, 60.5}, {-164.5, 63}, {-161, 64.5}, {-166, 64.6}, {-168, 65.6}, {-163, 66.5}},
+	// Greenland
+	{{-73, 78}, {-66, 80.5}, {-60, 82}, {-45, 82.5}, {-30, 83.5}, {-20, 82}, {-12, 81.5}, {-18, 77}, {-20, 75}, {-22, 72}, {-22, 70.1}, {-26, 68.5}, {-32, 68}, {-38, 65.5}, {-41, 63}, {-43, 60}, {-45, 60.2}, {-48, 61}, {-51, 63.5}, {-53, 66.5}, {-53.5, 69}, {-51, 70.5}, {-55, 72}, {-58, 75.5}, {-66, 76}},
+	// Baffin Island
+	{{-80, 73.5}, {-73, 71.5}, {-67, 69.8}, {-61.8, 66.6}, {-64, 65}, {-65, 62.6}, {-68, 63.5}, {-72, 64.7}, {-77, 64.2}, {-78, 65.5}, {-73, 67}, {-79, 70}},
+	// Victoria Island
+	{{-118, 71.5}, {-110, 73}, {-101, 72.5}, {-101, 69.5}, {-108, 68.9}, {-118, 69.4}},
+	// Banks Island
+	{{-125.5, 72}, {-120, 74.3}, {-116, 73.3}, {-120.5, 71.5}},
+	// Devon and Ellesmere Islands
+	{{-92, 74.6}, {-80.5, 74.5}, {-78, 76.2}, {-75, 78}, {-68, 80.5}, {-61, 82.5}, {-75, 83}, {-90, 81.5}, {-92, 79}, {-90, 77}},
+	// Cuba
+	{{-84.9, 21.9}, {-82, 23.2}, {-79, 22.6}, {-75.5, 21.1}, {-74.1, 20.2}, {-77.5, 19.9}, {-78, 21.5}, {-81.5, 22.2}},
+	// Hispaniola
+	{{-74.4, 18.5}, {-72.7, 19.9}, {-69.9, 19.7}, {-68.3, 18.5}, {-71.3, 17.6}, {-74.4, 18.2}},
+	// South America
+	{{-77.3, 8.7}, {-75.5, 10.5}, {-71.5, 12.4}, {-70, 12.2}, {-68, 10.6}, {-64, 10.6}, {-61.8, 10.7}, {-60.5, 8.5}, {-57, 6}, {-52, 5}, {-51, 4}, {-50, 1.8}, {-49.5, 0}, {-48, -1}, {-44.3, -2.5}, {-41, -3}, {-38.5, -3.7}, {-35.2, -5.5}, {-34.8, -7.5}, {-35.5, -9.8}, {-37, -11.3}, {-38.5, -13}, {-39, -17.5}, {-40, -20.3}, {-41, -22}, {-43.2, -23}, {-46.3, -24}, {-48.5, -26.2}, {-48.6, -28.5}, {-50.5, -31}, {-53, -33.7}, {-55, -35}, {-57.5, -35}, {-57, -36.3}, {-57.5, -38.2}, {-62, -39}, {-62.3, -40.8}, {-65, -41}, {-64.5, -42.5}, {-65.2, -45}, {-67.5, -46.5}, {-65.8, -47.8}, {-68.5, -50.5}, {-68.3, -52.4}, {-65.1, -54.7}, {-67.5, -55.6}, {-71, -54.3}, {-74.5, -52}, {-75.5, -48.5}, {-74, -45}, {-73.7, -43.5}, {-74, -42}, {-73.5, -39}, {-73.6, -37.2}, {-72, -34}, {-71.5, -30}, {-71.3, -27}, {-70.4, -23.5}, {-70.2, -18.4}, {-71.5, -17.3}, {-75, -15.2}, {-76.3, -13.5}, {-78, -10.5}, {-79.5, -7.5}, {-81.3, -4.7}, {-80.3, -3.4}, {-80, -2.2}, {-80.9, -1}, {-80.1, 0.8}, {-78.9, 1.5}, {-77.4, 4}, {-77.5, 6.5}, {-77.9, 7.3}},
+	// Europe and Asia; Chukotka runs past 180°, and the map wraps it around
+	{{-5.6, 36}, {-6.3, 36.8}, {-8.9, 37}, {-9.5, 38.8}, {-8.7, 41}, {-9.3, 43}, {-8, 43.7}, {-4, 43.4}, {-1.8, 43.4}, {-1.2, 46}, {-2.5, 47.3}, {-4.7, 48.4}, {-1.6, 48.7}, {-1.5, 49.7}, {0.2, 49.5}, {1.6, 50.9}, {3.2, 51.3}, {4.5, 52.5}, {4.8, 53}, {7, 53.5}, {8.6, 53.9}, {8.6, 55.4}, {8.1, 56.6}, {10.6, 57.7}, {10.9, 56.4}, {10, 55}, {11, 54}, {12, 54.3}, {14.3, 53.9}, {18.5, 54.8}, {21, 55.3}, {21.2, 56.5}, {21.5, 57.6}, {24, 57.4}, {23.5, 59.2}, {28, 59.5}, {30, 59.9}, {28.5, 60.5}, {22.9, 59.8}, {21.4, 60.8}, {21.3, 62.5}, {25.2, 65}, {22, 65.8}, {21, 64.5}, {17.5, 62.3}, {18.8, 60}, {16.5, 57.5}, {16.5, 56.3}, {14.2, 55.4}, {12.8, 55.6}, {12.5, 56.5}, {11.5, 58.5}, {10.5, 59.3}, {8, 58.1}, {5.5, 58.9}, {5, 61}, {5.1, 62}, {7, 62.8}, {10, 64}, {12.5, 66}, {14.5, 68}, {15.5, 68.7}, {19, 69.8}, {23.5, 70.8}, {28, 71.1}, {31, 70.3}, {33, 69.3}, {36, 69}, {41, 67.8}, {40, 66.3}, {37.5, 66}, {35, 64.4}, {38, 64.2}, {40.5, 64.6}, {44, 66.1}, {44, 68.3}, {46, 68}, {53, 68.3}, {54, 68.9}, {58, 68.9}, {60, 68.8}, {66.5, 70.5}, {68.5, 73}, {73, 71.5}, {80, 72.5}, {87, 74.5}, {96, 76.2}, {104, 77.7}, {112, 76.5}, {113.5, 73.5}, {127, 73.5}, {130, 71}, {140, 72.5}, {150, 71.5}, {160, 69.7}, {170, 70}, {178, 69.3}, {186, 67.3}, {190.3, 66}, {186, 64.3}, {182, 64.8}, {179, 62.3}, {174, 61.8}, {170, 60}, {163, 59.9}, {163.5, 56.2}, {160, 53}, {156.7, 51}, {156, 57.5}, {158, 61.9}, {152, 59.2}, {143, 59.3}, {137, 54}, {141, 53}, {140.5, 48.5}, {135, 43.5}, {132, 43}, {129.5, 41}, {129.4, 37}, {129, 35.2}, {126.5, 34.5}, {126.2, 37}, {124.8, 39.6}, {121.5, 38.8}, {121, 40.8}, {117.8, 39.1}, {118.8, 37.5}, {122.5, 37.2}, {120.5, 36}, {119.5, 35}, {120.8, 32.5}, {121.9, 30.8}, {121.5, 28.5}, {119.5, 25.5}, {117, 23.5}, {113.5, 22.2}, {110, 21.5}, {109.8, 20.5}, {108, 21.5}, {106.5, 20.3}, {105.7, 18.5}, {108.8, 15.3}, {109.3, 12}, {106.8, 10.3}, {104.8, 8.6}, {105, 10.5}, {103, 11}, {100.9, 12.6}, {100, 13.5}, {99.2, 10.5}, {100.4, 7.2}, {103.3, 5.3}, {104.2, 1.4}, {101.3, 2.8}, {100.2, 5.3}, {98.3, 8}, {98.5, 12}, {97.6, 16.5}, {94.3, 16}, {94.3, 19.4}, {92.3, 21}, {90.5, 22.3}, {88.5, 21.6}, {86.8, 20.5}, {85, 19.3}, {82.3, 16.6}, {80.3, 15.5}, {80.2, 13}, {79.8, 10.3}, {77.5, 8.1}, {76.3, 9.9}, {74.8, 12.8}, {73.4, 16}, {72.8, 19}, {72.6, 21.3}, {70.2, 20.8}, {68.9, 22.4}, {67.4, 23.9}, {66.5, 25.4}, {61.6, 25.2}, {57.3, 25.9}, {56.4, 27.1}, {54, 26.7}, {51.5, 27.9}, {50.2, 30}, {48.5, 30}, {48, 29.3}, {49.5, 27}, {50.8, 24.8}, {51.6, 24.2}, {54.5, 24.3}, {56.3, 26.3}, {56.4, 24.9}, {58.7, 23.6}, {59.8, 22.4}, {57.8, 19}, {55, 17}, {52.2, 15.6}, {48.5, 14}, {45, 12.8}, {43.4, 12.7}, {42.7, 15.5}, {40, 20}, {38.5, 22.5}, {36.5, 26}, {34.8, 28.2}, {33.5, 28.2}, {32.5, 30}, {34.5, 31.5}, {35, 33}, {36, 35.8}, {36, 36.9}, {32.5, 36.1}, {30.5, 36.3}, {28, 36.7}, {26.4, 38.5}, {26.3, 40.1}, {26, 40.8}, {23.5, 40.3}, {22.7, 38.3}, {22.9, 36.4}, {21.7, 36.8}, {21.1, 38.3}, {19.4, 40.5}, {19.5, 42}, {16.5, 43.5}, {13.6, 45.6}, {12.3, 45.3}, {12.4, 44.2}, {13.6, 43.5}, {15.9, 41.9}, {18.5, 40.2}, {17, 39.5}, {16.6, 38.4}, {15.6, 38}, {16.1, 39.6}, {15.7, 40}, {14.2, 40.8}, {12.3, 41.8}, {10.5, 43}, {9, 44.4}, {7, 43.6}, {4.5, 43.4}, {3.1, 42.4}, {3.2, 41.9}, {0.9, 41}, {-0.3, 39.5}, {0.2, 38.7}, {-0.7, 37.6}, {-2.2, 36.7}, {-4.5, 36.7}},
+	// Africa
+	{{-5.9, 35.8}, {-2, 35.1}, {1, 36.5}, {3.5, 36.8}, {8.5, 36.9}, {10.3, 37.2}, {11.1, 36.9}, {10.3, 36}, {11.1, 35.2}, {10, 34.1}, {11.5, 33}, {15.2, 32.3}, {19, 30.3}, {20, 31.5}, {20.1, 32.5}, {23, 32.6}, {25, 31.7}, {29, 30.9}, {31, 31.6}, {32.3, 31.3}, {32.5, 30}, {32.6, 29.5}, {34.5, 26.5}, {35.5, 24}, {37.2, 21}, {38.5, 18}, {39.7, 15.5}, {41.5, 13.9}, {43.3, 12.4}, {44, 10.5}, {51.2, 11.8}, {51, 10.4}, {49, 6}, {47.5, 4.5}, {43, 0}, {40.5, -2.5}, {39.2, -5}, {39.5, -8}, {40.5, -10.5}, {40.5, -15}, {37, -17.5}, {35, -20}, {35.5, -23.8}, {32.7, -25.9}, {32.4, -28.5}, {31, -30}, {28, -33}, {25.6, -34}, {22, -34.2}, {20, -34.8}, {18.4, -34.3}, {18.2, -32.5}, {16.5, -28.6}, {15.2, -26.5}, {14.5, -22.8}, {11.8, -17.2}, {13.4, -12.5}, {12.3, -6.1}, {9, -1}, {9.5, 2}, {9.7, 4}, {8.5, 4.6}, {6, 4.3}, {4.5, 6.3}, {2, 6.3}, {-2, 4.8}, {-4.5, 5.2}, {-7.5, 4.4}, {-11.5, 6.8}, {-13.3, 9}, {-15, 11}, {-16.8, 13.4}, {-17.5, 14.7}, {-16.5, 16.5}, {-16.2, 19.5}, {-17, 21}, {-15, 23.8}, {-13, 27.8}, {-10, 29.5}, {-9.8, 31.5}, {-8.5, 33.3}, {-6.8, 34}},
+	// Madagascar
+	{{49.3, -12}, {50.5, -15.5}, {49.5, -17}, {47.2, -25}, {45, -25.5}, {43.7, -23.5}, {43.3, -22}, {44.5, -16.2}, {46.5, -15.7}, {48, -13.5}},
+	// Great Britain
+	{{-5.7, 50}, {-3, 50.7}, {1.4, 51.2}, {1.7, 52.7}, {0.3, 53.5}, {-0.5, 54.5}, {-1.8, 55.6}, {-2, 57.5}, {-1.8, 57.6}, {-3, 58.6}, {-5, 58.6}, {-6.2, 57.5}, {-5.6, 56.3}, {-5.8, 55.3}, {-4.8, 54.8}, {-3.4, 54.9}, {-3, 53.8}, {-3.2, 53.3}, {-4.6, 53.3}, {-4.7, 52.8}, {-4.1, 52.3}, {-5.3, 51.8}, {-4, 51.5}, {-3, 51.5}, {-4.5, 51.1}},
+	// Ireland
+	{{-6, 52.2}, {-6.2, 53.9}, {-5.5, 54.6}, {-6.2, 55.2}, {-8.2, 55.2}, {-8.6, 54.4}, {-10, 54}, {-9.3, 53.2}, {-10, 52}, {-9.5, 51.5}, {-8, 51.8}},
+	// Iceland
+	{{-22.5, 64}, {-24, 65.5}, {-22, 66.4}, {-18, 66.2}, {-14.5, 66.4}, {-13.5, 65.1}, {-15, 64.2}, {-18.5, 63.4}, {-21, 63.8}},
+	// Sicily
+	{{12.4, 37.9}, {15.6, 38.3}, {15.1, 36.7}},
+	// Sardinia
+	{{8.4, 39}, {9.6, 39.1}, {9.7, 41}, {8.2, 40.9}},
+	// Svalbard
+	{{11, 78.5}, {17, 76.6}, {22, 77.5}, {27, 79.8}, {17, 80.2}, {11, 79.8}},
+	// Novaya Zemlya
+	{{52, 71.5}, {56, 70.6}, {57.5, 70.8}, {60, 74}, {68, 76.8}, {63, 77}, {55, 75.8}, {52, 73}},
+	// Sri Lanka
+	{{79.8, 9.8}, {81.9, 7.5}, {81.6, 6.3}, {80.2, 6}, {79.8, 8}},
+	// Hainan
+	{{108.6, 19.2}, {110.6, 20.1}, {111, 19.6}, {109.5, 18.2}},
+	// Taiwan
+	{{121, 25.3}, {122, 25}, {121.5, 23}, {120.8, 21.9}, {120.1, 23}},
+	// Honshu
+	{{141.5, 41.4}, {142, 39.5}, {141, 38.2}, {140.9, 36}, {139.8, 35}, {138.8, 34.6}, {137, 34.6}, {135.8, 33.5}, {135.1, 34.3}, {132.5, 34.3}, {131, 34}, {131.5, 34.7}, {133, 35.6}, {136, 35.8}, {137, 37.1}, {138.5, 37.9}, {140, 39.9}, {140.1, 41.2}},
+	// Kyushu
+	{{130, 33.7}, {131.9, 33.3}, {131.5, 31.4}, {130.5, 31}, {129.8, 32.7}},
+	// Shikoku
+	{{132.5, 33}, {134.7, 33.8}, {134.3, 34.3}, {132.7, 33.9}},
+	// Hokkaido
+	{{140, 41.5}, {141.2, 41.8}, {143.3, 42}, {145.6, 43.3}, {144.5, 44}, {141.6, 45.5}, {141.6, 43.3}, {140.3, 43.2}, {139.9, 42.2}},
+	// Sakhalin
+	{{142, 46}, {143.5, 46.8}, {143, 49.5}, {144.7, 49}, {143, 54}, {142.5, 54.3}, {142, 51.5}, {141.8, 48}},
+	// Luzon
+	{{120.6, 18.5}, {122.2, 18.5}, {122, 16.5}, {124, 13}, {121.5, 13.8}, {120.6, 14.4}, {119.8, 16}},
+	// Mindanao
+	{{122, 7}, {126.5, 7}, {126.2, 9.3}, {125.3, 9.8}, {123.6, 8.2}},
+	// Borneo
+	{{109, 1.5}, {109.6, 2.1}, {111.5, 2.5}, {113.5, 3.5}, {116, 6.5}, {117.5, 7}, {119, 5.2}, {118, 4.3}, {117.8, 1}, {116.5, -1.5}, {116, -3.8}, {114.5, -3.5}, {110.5, -3}, {109.5, -1}},
+	// Sumatra
+	{{95.3, 5.6}, {97.5, 5.2}, {100.5, 2}, {103.8, -1}, {106, -3}, {105.8, -5.8}, {104.5, -5.9}, {102.3, -4}, {100.5, -1}, {98.5, 1.8}},
+	// Java
+	{{105.2, -6.8}, {108, -6.2}, {111, -6.4}, {114.5, -7.7}, {114.4, -8.7}, {111, -8.2}, {106.5, -7.4}},
+	// Sulawesi
+	{{119.5, -5.5}, {120.4, -5.6}, {120.5, -2.9}, {121.5, -4.8}, {123, -4.5}, {121.3, -1.9}, {123.3, -0.9}, {121, -1.2}, {121, 0.5}, {124.9, 1.5}, {120.5, 1}, {119.8, 0}, {119, -3}},
+	// New Guinea
+	{{131, -1.3}, {134, -0.9}, {137.5, -1.6}, {141, -2.6}, {145, -4.3}, {147.5, -6}, {148, -8}, {150.5, -10.3}, {147, -10.2}, {144, -7.8}, {143.5, -9}, {141, -9.1}, {138, -8.3}, {137.8, -5.3}, {135, -4.3}, {132.8, -4}, {132, -2.8}},
+	// Australia
+	{{113.5, -22}, {114, -26.5}, {115, -29.5}, {115, -33.6}, {117.9, -35.1}, {123.5, -33.9}, {126, -32.3}, {131, -31.5}, {134.2, -32.8}, {135.8, -34.8}, {138, -33}, {138.5, -35.6}, {140, -37.9}, {143.5, -38.8}, {146.3, -39.1}, {150, -37.5}, {151.2, -33.9}, {153.6, -28.6}, {153, -25}, {150.8, -22.5}, {149, -20.5}, {146.3, -19}, {145.3, -15}, {143.5, -14}, {142.5, -10.7}, {141.6, -12.8}, {141.5, -17}, {140, -17.7}, {136.7, -15.9}, {135.7, -14}, {136.9, -12.3}, {132.6, -11.5}, {130.9, -12.4}, {129.5, -15}, {126.8, -13.8}, {124.8, -16.3}, {122.2, -18}, {121, -19.5}, {117, -20.6}, {114.2, -21.8}},
+	// Tasmania
+	{{144.6, -40.7}, {148.3, -40.9}, {148, -43.2}, {146.8, -43.6}, {145.2, -42.2}},
+	// New Zealand's North Island
+	{{172.7, -34.4}, {174.8, -36.8}, {178.5, -37.7}, {177.9, -39.2}, {176.8, -40.2}, {175, -41.6}, {174.6, -39.8}, {173.8, -39.2}, {174.6, -38}},
+	// New Zealand's South Island
+	{{172.7, -40.5}, {174.3, -41.7}, {173, -43.8}, {171.2, -44.5}, {170.8, -45.9}, {169, -46.6}, {166.5, -46}, {168.4, -44}, {170.7, -42.9}, {172.1, -41}},
+	// Antarctica
+	{{-180, -78}, {-165, -78.5}, {-150, -76.5}, {-135, -74.5}, {-120, -73.8}, {-100, -73}, {-80, -73}, {-75, -70}, {-68, -67.5}, {-63, -64.5}, {-57, -63.3}, {-60, -66}, {-62, -70}, {-61, -74}, {-50, -77.5}, {-35, -78}, {-27, -75}, {-15, -72}, {0, -70.5}, {15, -70}, {30, -69.5}, {40, -68.5}, {55, -66.5}, {70, -68}, {73, -69.8}, {80, -67.5}, {90, -66.5}, {105, -66}, {120, -66.5}, {135, -66}, {150, -68.5}, {165, -71}, {170, -72}, {168, -76}, {165, -78}, {180, -78}, {180, -90}, {-180, -90}},
+}
+
+var seaPolygons = [][][2]float64{
+	// Black Sea
+	{{28, 41.2}, {27.8, 43}, {28.6, 44.3}, {30.5, 46.5}, {33.5, 46}, {32.5, 45.4}, {33.7, 44.4}, {36.5, 45.3}, {38.5, 47}, {37.5, 44.5}, {41.5, 41.5}, {36, 41.7}, {31, 41.1}},
+	// Caspian Sea
+	{{47, 44.5}, {49, 46.5}, {53, 47}, {53, 45}, {51, 44.5}, {52.8, 41.8}, {53.9, 40}, {54, 37.5}, {51, 36.8}, {49, 37.6}, {49.5, 40.2}, {47.6, 41.5}},
+}
+
+// rasterize returns the coverage of polygons, drawing each one again shifted by 360° so that it wraps around the map.
+func rasterize(polygons [][][2]float64) *image.Alpha {
+	point := func(p [2]float64, shift float64) (float32, float32) {
+		return float32((p[0] + shift + 180) / 360 * width), float32((90 - p[1]) / 180 * height)
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	for _, polygon := range polygons {
+		for _, shift := range []float64{-360, 0, 360} {
+			z := vector.NewRasterizer(width, height)
+			z.MoveTo(point(polygon[0], shift))
+			for _, p := range polygon[1:] {
+				z.LineTo(point(p, shift))
+			}
+			z.ClosePath()
+			z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+		}
+	}
+	return mask
+}
+
+// landColor turns from green near the equator to dry at the horse latitudes and to ice at the poles.
+func landColor(lat float64) color.RGBA {
+	lat = math.Abs(lat)
+	switch {
+	case lat > 66:
+		return ice
+	case lat > 58:
+		return mix(land, ice, (lat-58)/8)
+	case lat > 15 && lat < 35:
+		return mix(land, dry, math.Sin((lat-15)/20*math.Pi))
+	}
+	return land
+}
+
+func mix(a, b color.RGBA, t float64) color.RGBA {
+	return color.RGBA{
+		uint8(float64(a.R) + (float64(b.R)-float64(a.R))*t),
+		uint8(float64(a.G) + (float64(b.G)-float64(a.G))*t),
+		uint8(float64(a.B) + (float64(b.B)-float64(a.B))*t),
+		255,
+	}
+}
+
+func main() {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(ocean), image.Point{}, draw.Src)
+
+	landMask := rasterize(landPolygons)
+	for y := 0; y < height; y++ {
+		lat := 90 - (float64(y)+0.5)/height*180
+		row := image.Rect(0, y, width, y+1)
+		draw.DrawMask(img, row, image.NewUniform(landColor(lat)), image.Point{}, landMask, row.Min, draw.Over)
+	}
+	draw.DrawMask(img, img.Bounds(), image.NewUniform(ocean), image.Point{}, rasterize(seaPolygons), image.Point{}, draw.Over)
+
+	f, err := os.Create("worldmap.png")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	err = encoder.Encode(f, img)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
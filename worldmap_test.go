@@ -0,0 +1,144 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSubsolarPoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		time     time.Time
+		lat, lon float64
+	}{
+		// the longitudes follow the equation of time, such as the sun running 7 minutes late in March
+		{"march equinox", time.Date(2024, 3, 20, 3, 6, 0, 0, time.UTC), 0, 135.4},
+		{"june solstice", time.Date(2024, 6, 20, 20, 51, 0, 0, time.UTC), 23.44, -132.4},
+		{"december solstice", time.Date(2024, 12, 21, 9, 21, 0, 0, time.UTC), -23.44, 39.3},
+		{"september equinox noon", time.Date(2024, 9, 22, 12, 0, 0, 0, time.UTC), 0, -1.8},
+	}
+	for _, test := range tests {
+		lat, lon := subsolarPoint(test.time)
+		if math.Abs(lat-test.lat) > 0.2 {
+			t.Errorf("%s: latitude %.2f, want %.2f", test.name, lat, test.lat)
+		}
+		if math.Abs(lon-test.lon) > 0.5 {
+			t.Errorf("%s: longitude %.2f, want %.2f", test.name, lon, test.lon)
+		}
+	}
+}
+
+// mapPoint returns the pixel of a 2:1 map filling a width*2 by width image.
+func mapPoint(width int, lat, lon float64) image.Point {
+	return image.Pt(int((lon+180)/360*float64(width)), int((90-lat)/180*float64(width/2)))
+}
+
+func TestDayNightMapShading(t *testing.T) {
+	white := filepath.Join(t.TempDir(), "white.png")
+	writePNG(t, white, 40, 20, color.White)
+
+	// noon at Greenwich on the equinox
+	m := DayNightMap{Map: white, Time: time.Date(2024, 9, 22, 12, 0, 0, 0, time.UTC)}
+	img, err := m.Generate(360, 180)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba := toRGBA(img)
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     uint8
+	}{
+		{"noon", 0, 0, 255},
+		{"morning", 30, -60, 255},
+		{"midnight", 0, 179, 89},
+		{"evening", -30, 120, 89},
+	}
+	for _, test := range tests {
+		p := mapPoint(360, test.lat, test.lon)
+		if c := rgba.RGBAAt(p.X, p.Y); !nearRGBA(c, color.RGBA{test.want, test.want, test.want, 255}) {
+			t.Errorf("%s: %v at %v, want %d", test.name, c, p, test.want)
+		}
+	}
+}
+
+func TestDayNightMapDefaults(t *testing.T) {
+	// the embedded map at the current time
+	img, err := DayNightMap{}.Generate(256, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now, err := DayNightMap{Time: time.Now()}.Generate(256, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the terminator may have moved by a pixel between the renders
+	a, b := toRGBA(img), toRGBA(now)
+	different := 0
+	for i := 0; i < len(a.Pix); i += 4 {
+		if !nearRGBA(a.RGBAAt(i/4%256, i/4/256), b.RGBAAt(i/4%256, i/4/256)) {
+			different++
+		}
+	}
+	if different > len(a.Pix)/4/50 {
+		t.Errorf("%d pixels differ from a render of the current time", different)
+	}
+
+	// oceans and land differ at noon on the equator
+	m := DayNightMap{Time: time.Date(2024, 9, 22, 12, 0, 0, 0, time.UTC)}
+	img, err = m.Generate(360, 180)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba := toRGBA(img)
+	atlantic, africa := mapPoint(360, 0, -25), mapPoint(360, 5, 20)
+	if c := rgba.RGBAAt(atlantic.X, atlantic.Y); c.B <= c.G {
+		t.Errorf("Atlantic is %v", c)
+	}
+	if c := rgba.RGBAAt(africa.X, africa.Y); c.G <= c.B {
+		t.Errorf("Africa is %v", c)
+	}
+}
+
+func TestDayNightMapCities(t *testing.T) {
+	black := filepath.Join(t.TempDir(), "black.png")
+	writePNG(t, black, 40, 20, color.Black)
+
+	m := DayNightMap{
+		Map:    black,
+		Time:   time.Date(2024, 9, 22, 12, 0, 0, 0, time.UTC),
+		Cities: []City{{"Null Island", 0, 0, "UTC"}},
+	}
+	img, err := m.Generate(400, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba := toRGBA(img)
+	p := mapPoint(400, 0, 0)
+	if c := rgba.RGBAAt(p.X, p.Y); c != (color.RGBA{255, 80, 60, 255}) {
+		t.Errorf("marker is %v", c)
+	}
+	label := 0
+	for y := p.Y - 10; y < p.Y+10; y++ {
+		for x := p.X + 4; x < p.X+100; x++ {
+			if rgba.RGBAAt(x, y).R > 128 {
+				label++
+			}
+		}
+	}
+	if label == 0 {
+		t.Error("no label next to the marker")
+	}
+
+	m.Cities[0].TimeZone = "Nowhere/Null_Island"
+	_, err = m.Generate(400, 200)
+	if err == nil {
+		t.Error("unknown time zone: no error")
+	}
+}
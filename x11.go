@@ -0,0 +1,363 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// x11Conn is a minimal X11 protocol client, just enough to set the root window background.
+// Requests are sent in little-endian byte order.
+type x11Conn struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	seq        uint16
+	idBase     uint32
+	idMask     uint32
+	idNext     uint32
+	maxRequest int
+	imageMSB   bool
+	formats    map[uint8]x11Format
+	screen     x11Screen
+}
+
+type x11Format struct {
+	bitsPerPixel uint8
+	scanlinePad  uint8
+}
+
+type x11Screen struct {
+	root             uint32
+	width, height    int
+	depth            uint8
+	visual           uint32
+	visualClass      uint8
+	red, green, blue uint32
+}
+
+// x11AtomPixmap is the predefined PIXMAP atom.
+const x11AtomPixmap = 20
+
+// x11Error is an error returned by the X server.
+type x11Error struct {
+	code  uint8
+	major uint8
+	value uint32
+}
+
+func (err x11Error) Error() string {
+	return fmt.Sprintf("x11 error %d for request %d (value %d)", err.code, err.major, err.value)
+}
+
+// dialX11 connects to the display named by $DISPLAY and returns ErrUnsupportedDE if it is not set.
+func dialX11() (*x11Conn, error) {
+	display := os.Getenv("DISPLAY")
+	i := strings.LastIndexByte(display, ':')
+	if display == "" || i == -1 {
+		return nil, ErrUnsupportedDE
+	}
+
+	host, number, screen := display[:i], display[i+1:], "0"
+	if j := strings.IndexByte(number, '.'); j != -1 {
+		number, screen = number[:j], number[j+1:]
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DISPLAY %q", display)
+	}
+	screenNumber, err := strconv.Atoi(screen)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DISPLAY %q", display)
+	}
+
+	var conn net.Conn
+	if host == "" || host == "unix" {
+		conn, err = net.Dial("unix", "/tmp/.X11-unix/X"+number)
+		if err != nil {
+			// some servers only listen on the abstract socket
+			conn, err = net.Dial("unix", "@/tmp/.X11-unix/X"+number)
+		}
+	} else {
+		conn, err = net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(6000+n)))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &x11Conn{conn: conn, reader: bufio.NewReader(conn)}
+	err = c.setup(number, screenNumber)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *x11Conn) close() error {
+	return c.conn.Close()
+}
+
+// xauthCookie returns the MIT-MAGIC-COOKIE-1 of a local display from the Xauthority file, if there is one.
+func xauthCookie(number string) ([]byte, []byte) {
+	name := os.Getenv("XAUTHORITY")
+	if name == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		name = filepath.Join(home, ".Xauthority")
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+
+	hostname, _ := os.Hostname()
+	reader := bufio.NewReader(f)
+	readString := func() ([]byte, error) {
+		var length uint16
+		err := binary.Read(reader, binary.BigEndian, &length)
+		if err != nil {
+			return nil, err
+		}
+		s := make([]byte, length)
+		_, err = io.ReadFull(reader, s)
+		return s, err
+	}
+
+	for {
+		var family uint16
+		if binary.Read(reader, binary.BigEndian, &family) != nil {
+			return nil, nil
+		}
+
+		var fields [4][]byte
+		for i := range fields {
+			fields[i], err = readString()
+			if err != nil {
+				return nil, nil
+			}
+		}
+		address, display, authName, authData := fields[0], fields[1], fields[2], fields[3]
+
+		// 256 is FamilyLocal and 65535 FamilyWild
+		local := (family == 256 && string(address) == hostname) || family == 65535
+		if local && (len(display) == 0 || string(display) == number) && string(authName) == "MIT-MAGIC-COOKIE-1" {
+			return authName, authData
+		}
+	}
+}
+
+func (c *x11Conn) setup(number string, screenNumber int) error {
+	authName, authData := xauthCookie(number)
+	request := x11Request{'l', 0}.u16(11).u16(0).u16(uint16(len(authName))).u16(uint16(len(authData))).u16(0)
+	request = request.pad(authName).pad(authData)
+	_, err := c.conn.Write(request)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 8)
+	_, err = io.ReadFull(c.reader, header)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, int(binary.LittleEndian.Uint16(header[6:]))*4)
+	_, err = io.ReadFull(c.reader, data)
+	if err != nil {
+		return err
+	}
+	if header[0] != 1 {
+		reason := data
+		if header[0] == 0 && int(header[1]) <= len(data) {
+			reason = data[:header[1]]
+		}
+		return fmt.Errorf("x11 connection refused: %s", strings.TrimSpace(string(reason)))
+	}
+	if len(data) < 32 {
+		return errors.New("x11 setup reply too short")
+	}
+
+	c.idBase = binary.LittleEndian.Uint32(data[4:])
+	c.idMask = binary.LittleEndian.Uint32(data[8:])
+	vendorLength := int(binary.LittleEndian.Uint16(data[16:]))
+	c.maxRequest = int(binary.LittleEndian.Uint16(data[18:])) * 4
+	screens, formats := int(data[20]), int(data[21])
+	c.imageMSB = data[22] == 1
+
+	offset := 32 + (vendorLength+3)/4*4
+	c.formats = map[uint8]x11Format{}
+	for i := 0; i < formats; i++ {
+		if offset+8 > len(data) {
+			return errors.New("x11 setup reply too short")
+		}
+		c.formats[data[offset]] = x11Format{data[offset+1], data[offset+2]}
+		offset += 8
+	}
+
+	for i := 0; i < screens; i++ {
+		if offset+40 > len(data) {
+			return errors.New("x11 setup reply too short")
+		}
+		screen := x11Screen{
+			root:   binary.LittleEndian.Uint32(data[offset:]),
+			width:  int(binary.LittleEndian.Uint16(data[offset+20:])),
+			height: int(binary.LittleEndian.Uint16(data[offset+22:])),
+			visual: binary.LittleEndian.Uint32(data[offset+32:]),
+			depth:  data[offset+38],
+		}
+		depths := int(data[offset+39])
+		offset += 40
+
+		for j := 0; j < depths; j++ {
+			if offset+8 > len(data) {
+				return errors.New("x11 setup reply too short")
+			}
+			visuals := int(binary.LittleEndian.Uint16(data[offset+2:]))
+			offset += 8
+			for k := 0; k < visuals; k++ {
+				if offset+24 > len(data) {
+					return errors.New("x11 setup reply too short")
+				}
+				if binary.LittleEndian.Uint32(data[offset:]) == screen.visual {
+					screen.visualClass = data[offset+4]
+					screen.red = binary.LittleEndian.Uint32(data[offset+8:])
+					screen.green = binary.LittleEndian.Uint32(data[offset+12:])
+					screen.blue = binary.LittleEndian.Uint32(data[offset+16:])
+				}
+				offset += 24
+			}
+		}
+
+		if i == screenNumber {
+			c.screen = screen
+			return nil
+		}
+	}
+	return fmt.Errorf("x11 screen %d not found", screenNumber)
+}
+
+// x11Request builds the body of a request.
+type x11Request []byte
+
+func (request x11Request) u8(v uint8) x11Request {
+	return append(request, v)
+}
+
+func (request x11Request) u16(v uint16) x11Request {
+	return append(request, byte(v), byte(v>>8))
+}
+
+func (request x11Request) u32(v uint32) x11Request {
+	return append(request, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// pad appends b padded to a multiple of 4 bytes.
+func (request x11Request) pad(b []byte) x11Request {
+	request = append(request, b...)
+	for len(request)%4 != 0 {
+		request = append(request, 0)
+	}
+	return request
+}
+
+func (c *x11Conn) newID() uint32 {
+	c.idNext++
+	return c.idBase | (c.idNext<<bits.TrailingZeros32(c.idMask))&c.idMask
+}
+
+// send writes a request with the given opcode, detail byte and body, returning its sequence number.
+func (c *x11Conn) send(opcode, detail uint8, body x11Request) (uint16, error) {
+	request := x11Request{opcode, detail}.u16(0).pad(body)
+	if len(request) > c.maxRequest {
+		return 0, errors.New("x11 request too large")
+	}
+	binary.LittleEndian.PutUint16(request[2:], uint16(len(request)/4))
+
+	_, err := c.conn.Write(request)
+	if err != nil {
+		return 0, err
+	}
+	c.seq++
+	return c.seq, nil
+}
+
+// request sends a request and waits for its reply, returning any error the server sent in the meantime.
+func (c *x11Conn) request(opcode, detail uint8, body x11Request) ([]byte, error) {
+	seq, err := c.send(opcode, detail, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		packet := make([]byte, 32)
+		_, err := io.ReadFull(c.reader, packet)
+		if err != nil {
+			return nil, err
+		}
+
+		switch packet[0] & 0x7f {
+		case 0:
+			return nil, x11Error{packet[1], packet[10], binary.LittleEndian.Uint32(packet[4:])}
+		case 1, 35:
+			// replies and generic events carry additional data
+			extra := make([]byte, int(binary.LittleEndian.Uint32(packet[4:]))*4)
+			_, err = io.ReadFull(c.reader, extra)
+			if err != nil {
+				return nil, err
+			}
+			if packet[0] == 1 && binary.LittleEndian.Uint16(packet[2:]) == seq {
+				return append(packet, extra...), nil
+			}
+		}
+	}
+}
+
+// sync waits until the server processed every request sent so far.
+func (c *x11Conn) sync() error {
+	// GetInputFocus
+	_, err := c.request(43, 0, nil)
+	return err
+}
+
+func (c *x11Conn) internAtom(name string) (uint32, error) {
+	reply, err := c.request(16, 0, x11Request{}.u16(uint16(len(name))).u16(0).pad([]byte(name)))
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(reply[8:]), nil
+}
+
+// getProperty returns the type and value of a window property; the type is 0 if the property doesn't exist.
+func (c *x11Conn) getProperty(window, property uint32) (uint32, []byte, error) {
+	reply, err := c.request(20, 0, x11Request{}.u32(window).u32(property).u32(0).u32(0).u32(1<<16))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	format := int(reply[1])
+	length := int(binary.LittleEndian.Uint32(reply[16:])) * format / 8
+	if 32+length > len(reply) {
+		return 0, nil, errors.New("x11 property reply too short")
+	}
+	return binary.LittleEndian.Uint32(reply[8:]), reply[32 : 32+length], nil
+}
+
+func (c *x11Conn) changeProperty(window, property, typ uint32, format uint8, data []byte) error {
+	body := x11Request{}.u32(window).u32(property).u32(typ).u8(format).u8(0).u16(0).u32(uint32(len(data) * 8 / int(format)))
+	_, err := c.send(18, 0, body.pad(data))
+	return err
+}
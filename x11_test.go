@@ -0,0 +1,758 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math/bits"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubX is an X server that understands the requests sent by x11Conn.
+// Properties and pixmaps outlive connections, like on a real server.
+type stubX struct {
+	width, height int
+	maxRequest    int
+	crtcs         []stubCrtc
+	refuse        string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu         sync.Mutex
+	clients    uint32
+	atoms      map[string]uint32
+	properties map[uint32]stubProperty
+	pixmaps    map[uint32]*stubPixmap
+	putImages  int
+	killed     []uint32
+	requests   []uint8
+}
+
+type stubCrtc struct {
+	rect image.Rectangle
+	mode uint32
+}
+
+type stubProperty struct {
+	typ    uint32
+	format uint8
+	data   []byte
+}
+
+type stubPixmap struct {
+	width, height int
+	data          []byte
+}
+
+const (
+	stubRoot   = 0x100
+	stubVisual = 0x21
+	stubRandR  = 140
+)
+
+// startStubX serves s on an abstract unix socket and points DISPLAY at it for the rest of the test.
+func startStubX(t *testing.T, s *stubX) {
+	t.Helper()
+	s.atoms = map[string]uint32{}
+	s.properties = map[uint32]stubProperty{}
+	s.pixmaps = map[uint32]*stubPixmap{}
+
+	for n := 100; n < 200 && s.listener == nil; n++ {
+		name := fmt.Sprintf("/tmp/.X11-unix/X%d", n)
+		if _, err := os.Stat(name); err == nil {
+			continue
+		}
+		listener, err := net.Listen("unix", "@"+name)
+		if err != nil {
+			continue
+		}
+		s.listener = listener
+		setEnv(t, "DISPLAY", fmt.Sprintf(":%d", n))
+	}
+	if s.listener == nil {
+		t.Skip("no free display number")
+	}
+	setEnv(t, "XAUTHORITY", filepath.Join(t.TempDir(), "missing"))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer conn.Close()
+				err := s.serve(conn)
+				if err != nil {
+					t.Errorf("stub X server: %v", err)
+				}
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		s.listener.Close()
+		s.wg.Wait()
+	})
+}
+
+func (s *stubX) serve(conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+	header := make([]byte, 12)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return err
+	}
+	if header[0] != 'l' {
+		return fmt.Errorf("byte order %q", header[0])
+	}
+	authLength := (int(binary.LittleEndian.Uint16(header[6:]))+3)/4*4 + (int(binary.LittleEndian.Uint16(header[8:]))+3)/4*4
+	_, err = io.ReadFull(reader, make([]byte, authLength))
+	if err != nil {
+		return err
+	}
+
+	if s.refuse != "" {
+		reason := x11Request{}.pad([]byte(s.refuse))
+		reply := x11Request{0, uint8(len(s.refuse))}.u16(11).u16(0).u16(uint16(len(reason) / 4))
+		_, err = conn.Write(append(reply, reason...))
+		return err
+	}
+
+	s.mu.Lock()
+	s.clients++
+	idBase := s.clients << 21
+	s.mu.Unlock()
+
+	_, err = conn.Write(s.setupReply(idBase))
+	if err != nil {
+		return err
+	}
+
+	var seq uint16
+	for {
+		request := make([]byte, 4)
+		_, err := io.ReadFull(reader, request)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		length := int(binary.LittleEndian.Uint16(request[2:])) * 4
+		if length > s.maxRequest {
+			return fmt.Errorf("request %d is %d bytes long", request[0], length)
+		}
+		request = append(request, make([]byte, length-4)...)
+		_, err = io.ReadFull(reader, request[4:])
+		if err != nil {
+			return err
+		}
+		seq++
+
+		reply, err := s.handle(request)
+		if err != nil {
+			return err
+		}
+		if reply != nil {
+			binary.LittleEndian.PutUint16(reply[2:], seq)
+			_, err = conn.Write(reply)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *stubX) setupReply(idBase uint32) []byte {
+	vendor := "stub"
+	data := x11Request{}.u32(0).u32(idBase).u32(1<<21 - 1).u32(0).u16(uint16(len(vendor))).u16(uint16(s.maxRequest / 4))
+	// one screen, one format, LSBFirst image and bitmap order, 32 bit scanline unit and pad, keycodes
+	data = data.u8(1).u8(1).u8(0).u8(0).u8(32).u8(32).u8(8).u8(255).u32(0).pad([]byte(vendor))
+	// depth 24 has 32 bits per pixel and 32 bit scanline pad
+	data = data.u8(24).u8(32).u8(32).pad(nil).u32(0)
+	// screen: root, colormap, white, black, event mask, size in pixels and millimeters, installed maps, visual,
+	// backing stores, save unders, depth and a single allowed depth
+	data = data.u32(stubRoot).u32(0x20).u32(0xffffff).u32(0).u32(0).u16(uint16(s.width)).u16(uint16(s.height)).u16(0).u16(0)
+	data = data.u16(1).u16(1).u32(stubVisual).u8(0).u8(0).u8(24).u8(1)
+	// depth 24 with a single TrueColor visual
+	data = data.u8(24).u8(0).u16(1).u32(0)
+	data = data.u32(stubVisual).u8(4).u8(8).u16(256).u32(0xff0000).u32(0xff00).u32(0xff).u32(0)
+
+	header := x11Request{1, 0}.u16(11).u16(0).u16(uint16(len(data) / 4))
+	return append(header, data...)
+}
+
+// stubReply returns a reply carrying extra, whose fixed fields the caller fills in.
+func stubReply(extra []byte) []byte {
+	extra = x11Request{}.pad(extra)
+	reply := make(x11Request, 32, 32+len(extra))
+	reply[0] = 1
+	binary.LittleEndian.PutUint32(reply[4:], uint32(len(extra)/4))
+	return append(reply, extra...)
+}
+
+func (s *stubX) atom(name string) uint32 {
+	if name == "PIXMAP" {
+		return x11AtomPixmap
+	}
+	atom, ok := s.atoms[name]
+	if !ok {
+		atom = uint32(100 + len(s.atoms))
+		s.atoms[name] = atom
+	}
+	return atom
+}
+
+func (s *stubX) handle(request []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request[0])
+
+	u16 := func(offset int) int { return int(binary.LittleEndian.Uint16(request[offset:])) }
+	u32 := func(offset int) uint32 { return binary.LittleEndian.Uint32(request[offset:]) }
+
+	switch request[0] {
+	case 16:
+		// InternAtom
+		reply := stubReply(nil)
+		binary.LittleEndian.PutUint32(reply[8:], s.atom(string(request[8:8+u16(4)])))
+		return reply, nil
+	case 18:
+		// ChangeProperty
+		if u32(4) != stubRoot {
+			return nil, fmt.Errorf("property of window %#x changed", u32(4))
+		}
+		format := request[16]
+		length := int(u32(20)) * int(format) / 8
+		s.properties[u32(8)] = stubProperty{u32(12), format, append([]byte(nil), request[24:24+length]...)}
+	case 20:
+		// GetProperty
+		property := s.properties[u32(8)]
+		reply := stubReply(property.data)
+		reply[1] = property.format
+		binary.LittleEndian.PutUint32(reply[8:], property.typ)
+		if property.format != 0 {
+			binary.LittleEndian.PutUint32(reply[16:], uint32(len(property.data)*8/int(property.format)))
+		}
+		return reply, nil
+	case 43:
+		// GetInputFocus
+		return stubReply(nil), nil
+	case 53:
+		// CreatePixmap
+		if request[1] != 24 {
+			return nil, fmt.Errorf("pixmap of depth %d", request[1])
+		}
+		width, height := u16(12), u16(14)
+		s.pixmaps[u32(4)] = &stubPixmap{width, height, make([]byte, width*height*4)}
+	case 72:
+		// PutImage
+		pixmap, ok := s.pixmaps[u32(4)]
+		if !ok || request[1] != 2 {
+			return nil, errors.New("invalid PutImage")
+		}
+		width, height, x, y := u16(12), u16(14), u16(16), u16(18)
+		for row := 0; row < height; row++ {
+			copy(pixmap.data[((y+row)*pixmap.width+x)*4:], request[24+row*width*4:24+(row+1)*width*4])
+		}
+		s.putImages++
+	case 73:
+		// GetImage of a whole pixmap
+		pixmap, ok := s.pixmaps[u32(4)]
+		if !ok || request[1] != 2 || u16(8) != 0 || u16(10) != 0 || u16(12) != pixmap.width || u16(14) != pixmap.height {
+			return nil, errors.New("invalid GetImage")
+		}
+		reply := stubReply(pixmap.data)
+		reply[1] = 24
+		binary.LittleEndian.PutUint32(reply[8:], stubVisual)
+		return reply, nil
+	case 98:
+		// QueryExtension
+		reply := stubReply(nil)
+		if string(request[8:8+u16(4)]) == "RANDR" && s.crtcs != nil {
+			reply[8], reply[9] = 1, stubRandR
+		}
+		return reply, nil
+	case 113:
+		// KillClient
+		s.killed = append(s.killed, u32(4))
+	case stubRandR:
+		return s.handleRandR(request)
+	}
+	return nil, nil
+}
+
+func (s *stubX) handleRandR(request []byte) ([]byte, error) {
+	switch request[1] {
+	case 0:
+		// RRQueryVersion
+		reply := stubReply(nil)
+		binary.LittleEndian.PutUint32(reply[8:], 1)
+		binary.LittleEndian.PutUint32(reply[12:], 5)
+		return reply, nil
+	case 25:
+		// RRGetScreenResourcesCurrent
+		ids := x11Request{}
+		for i := range s.crtcs {
+			ids = ids.u32(uint32(0x40 + i))
+		}
+		reply := stubReply(ids)
+		binary.LittleEndian.PutUint32(reply[12:], 7)
+		binary.LittleEndian.PutUint16(reply[16:], uint16(len(s.crtcs)))
+		return reply, nil
+	case 20:
+		// RRGetCrtcInfo
+		crtc := s.crtcs[binary.LittleEndian.Uint32(request[4:])-0x40]
+		reply := stubReply(nil)
+		binary.LittleEndian.PutUint16(reply[12:], uint16(crtc.rect.Min.X))
+		binary.LittleEndian.PutUint16(reply[14:], uint16(crtc.rect.Min.Y))
+		binary.LittleEndian.PutUint16(reply[16:], uint16(crtc.rect.Dx()))
+		binary.LittleEndian.PutUint16(reply[18:], uint16(crtc.rect.Dy()))
+		binary.LittleEndian.PutUint32(reply[20:], crtc.mode)
+		return reply, nil
+	}
+	return nil, fmt.Errorf("RandR request %d", request[1])
+}
+
+func (s *stubX) property(name string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.properties[s.atom(name)].data
+}
+
+func (s *stubX) pixmapProperty(name string) uint32 {
+	data := s.property(name)
+	if len(data) != 4 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(data)
+}
+
+// the band colors avoid 0 and 255 in every channel so that swapped or dropped channels show up
+var (
+	green = color.RGBA{40, 180, 70, 255}
+	red   = color.RGBA{200, 50, 30, 255}
+	blue  = color.RGBA{30, 60, 190, 255}
+	black = color.RGBA{0, 0, 0, 255}
+)
+
+// bands returns a 60x20 image with green, red and blue vertical bands.
+func bands() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 60, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 60; x++ {
+			img.Set(x, y, []color.RGBA{green, red, blue}[x/20])
+		}
+	}
+	return img
+}
+
+func writeBands(t *testing.T) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "bands.png")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = png.Encode(f, bands())
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestX11Setup(t *testing.T) {
+	s := &stubX{width: 80, height: 40, maxRequest: 1024}
+	startStubX(t, s)
+
+	c, err := dialX11()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+
+	want := x11Screen{root: stubRoot, width: 80, height: 40, depth: 24, visual: stubVisual, visualClass: 4, red: 0xff0000, green: 0xff00, blue: 0xff}
+	if c.screen != want {
+		t.Errorf("screen = %+v, want %+v", c.screen, want)
+	}
+	if c.maxRequest != 1024 || c.imageMSB || c.formats[24] != (x11Format{32, 32}) {
+		t.Errorf("maxRequest = %d, imageMSB = %v, formats = %v", c.maxRequest, c.imageMSB, c.formats)
+	}
+	if c.idBase != 1<<21 || c.newID() == c.newID() {
+		t.Errorf("idBase = %#x", c.idBase)
+	}
+
+	// without RandR the whole screen is one monitor
+	monitors, err := c.monitors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(monitors, []image.Rectangle{image.Rect(0, 0, 80, 40)}) {
+		t.Errorf("monitors = %v", monitors)
+	}
+}
+
+func TestX11SetupErrors(t *testing.T) {
+	s := &stubX{width: 80, height: 40, maxRequest: 1024}
+	startStubX(t, s)
+
+	display := os.Getenv("DISPLAY")
+	setEnv(t, "DISPLAY", display+".1")
+	_, err := dialX11()
+	if err == nil || err.Error() != "x11 screen 1 not found" {
+		t.Errorf("dialX11 with screen 1 = %v", err)
+	}
+
+	setEnv(t, "DISPLAY", "")
+	_, err = dialX11()
+	if err != ErrUnsupportedDE {
+		t.Errorf("dialX11 without DISPLAY = %v, want ErrUnsupportedDE", err)
+	}
+
+	s.refuse = "No protocol specified"
+	setEnv(t, "DISPLAY", display)
+	_, err = dialX11()
+	if err == nil || !strings.HasSuffix(err.Error(), ": No protocol specified") {
+		t.Errorf("dialX11 refused = %v", err)
+	}
+}
+
+func TestSetX11(t *testing.T) {
+	s := &stubX{
+		width:  80,
+		height: 40,
+		// a 320 byte stride leaves room for 3 rows per PutImage
+		maxRequest: 1024,
+		crtcs: []stubCrtc{
+			{image.Rect(0, 0, 40, 40), 1},
+			{image.Rect(40, 0, 80, 40), 1},
+			// mirrored
+			{image.Rect(0, 0, 40, 40), 1},
+			// disabled
+			{image.Rect(20, 0, 60, 40), 0},
+		},
+	}
+	startStubX(t, s)
+
+	file := writeBands(t)
+
+	// each monitor crops the red middle band, while Span crops the sides of the bands across both monitors
+	crop := map[image.Point]color.RGBA{{5, 5}: red, {35, 35}: red, {45, 5}: red, {75, 35}: red}
+	span := map[image.Point]color.RGBA{{5, 20}: green, {40, 20}: red, {75, 20}: blue}
+	checkBackground := func(mode Mode, points map[image.Point]color.RGBA) uint32 {
+		t.Helper()
+		pixmap := s.pixmapProperty("_XROOTPMAP_ID")
+		if pixmap == 0 || s.pixmapProperty("ESETROOT_PMAP_ID") != pixmap {
+			t.Fatalf("_XROOTPMAP_ID = %#x, ESETROOT_PMAP_ID = %#x", pixmap, s.pixmapProperty("ESETROOT_PMAP_ID"))
+		}
+
+		s.mu.Lock()
+		requests := s.requests
+		s.mu.Unlock()
+		c, err := dialX11()
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := c.getPixmap(pixmap, 80, 40)
+		c.close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for point, want := range points {
+			if got := img.RGBAAt(point.X, point.Y); !nearColor(got, want) {
+				t.Errorf("%v: pixel %v is %v, want %v", mode, point, got, want)
+			}
+		}
+
+		// ChangeWindowAttributes, ClearArea, SetCloseDownMode and the final GetInputFocus
+		tail := requests[len(requests)-4:]
+		if !reflect.DeepEqual(tail, []uint8{2, 61, 112, 43}) {
+			t.Errorf("last requests = %v", tail)
+		}
+
+		got, err := getX11()
+		if err != nil || got != file {
+			t.Errorf("getX11() = %q, %v", got, err)
+		}
+		matches, err := x11ModeMatches(mode)
+		if err != nil || !matches {
+			t.Errorf("x11ModeMatches(%v) = %v, %v", mode, matches, err)
+		}
+		return pixmap
+	}
+
+	err := setX11(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := checkBackground(Crop, crop)
+	// 40 rows in chunks of 3
+	if s.putImages != 14 {
+		t.Errorf("%d PutImage requests, want 14", s.putImages)
+	}
+	if len(s.killed) != 0 {
+		t.Errorf("killed %#x without a previous background", s.killed)
+	}
+
+	// the second background frees the first, which both atoms point at
+	err = setX11Mode(Span)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := checkBackground(Span, span)
+	if second == first || !reflect.DeepEqual(s.killed, []uint32{first}) {
+		t.Errorf("killed %#x, want %#x", s.killed, first)
+	}
+
+	// another program replaced _XROOTPMAP_ID, so ESETROOT_PMAP_ID may still be in use
+	s.mu.Lock()
+	s.properties[s.atom("_XROOTPMAP_ID")] = stubProperty{x11AtomPixmap, 32, x11Request{}.u32(0x1234)}
+	s.mu.Unlock()
+	err = setX11(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBackground(Span, span)
+	if !reflect.DeepEqual(s.killed, []uint32{first}) {
+		t.Errorf("killed %#x, want only %#x", s.killed, first)
+	}
+}
+
+func TestRenderBackground(t *testing.T) {
+	screen := image.Rect(0, 0, 80, 40)
+	monitors := []image.Rectangle{image.Rect(0, 0, 40, 40), image.Rect(40, 0, 80, 40)}
+
+	// the points are away from the edges of the bands so that scaling doesn't blend them
+	tests := []struct {
+		mode   Mode
+		points map[image.Point]color.RGBA
+	}{
+		{Center, map[image.Point]color.RGBA{
+			{5, 5}: black, {5, 20}: green, {20, 20}: red, {35, 20}: blue, {20, 35}: black, {45, 20}: green,
+		}},
+		{Crop, map[image.Point]color.RGBA{
+			{2, 2}: red, {38, 38}: red, {42, 2}: red, {78, 38}: red,
+		}},
+		{Fit, map[image.Point]color.RGBA{
+			{20, 5}: black, {3, 19}: green, {20, 19}: red, {37, 19}: blue, {20, 35}: black, {43, 19}: green,
+		}},
+		{Span, map[image.Point]color.RGBA{
+			{5, 20}: green, {40, 20}: red, {75, 20}: blue,
+		}},
+		{Stretch, map[image.Point]color.RGBA{
+			{3, 5}: green, {20, 35}: red, {37, 20}: blue, {43, 5}: green,
+		}},
+		{Tile, map[image.Point]color.RGBA{
+			{5, 5}: green, {25, 5}: red, {5, 25}: green, {45, 5}: green, {65, 25}: red,
+		}},
+	}
+	for _, test := range tests {
+		canvas := renderBackground(bands(), screen, monitors, test.mode)
+		if canvas.Rect != screen {
+			t.Errorf("%v: canvas is %v", test.mode, canvas.Rect)
+		}
+		for point, want := range test.points {
+			got := canvas.RGBAAt(point.X, point.Y)
+			if !nearColor(got, want) {
+				t.Errorf("%v: pixel %v is %v, want %v", test.mode, point, got, want)
+			}
+		}
+	}
+}
+
+func nearColor(a, b color.RGBA) bool {
+	near := func(x, y uint8) bool {
+		d := int(x) - int(y)
+		return d > -8 && d < 8
+	}
+	return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B) && a.A == b.A
+}
+
+func TestOverlayMonitor(t *testing.T) {
+	s := &stubX{
+		width:      3840,
+		height:     1080,
+		maxRequest: 1024,
+		crtcs:      []stubCrtc{{image.Rect(0, 0, 1920, 1080), 1}, {image.Rect(1920, 0, 3840, 1080), 1}},
+	}
+	startStubX(t, s)
+
+	monitors, err := Monitors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(monitors, []image.Rectangle{image.Rect(0, 0, 1920, 1080), image.Rect(1920, 0, 3840, 1080)}) {
+		t.Errorf("Monitors() = %v", monitors)
+	}
+
+	effect, err := Overlay{{Template: "left"}, {Template: "right", Monitor: 2}}.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := effect.(textOverlay)
+	if !blocks[0].area.Empty() || blocks[1].area != monitors[1] || blocks[1].screen != image.Rect(0, 0, 3840, 1080) {
+		t.Errorf("blocks are anchored to %v and %v", blocks[0].area, blocks[1].area)
+	}
+
+	_, err = Overlay{{Template: "missing", Monitor: 3}}.Render()
+	if err == nil {
+		t.Error("Render accepted monitor 3 of 2")
+	}
+}
+
+// startXvfb runs Xvfb with a single screen of the given size and points DISPLAY at it for the rest of the test.
+// The test is skipped if Xvfb isn't installed.
+func startXvfb(t *testing.T, width, height int) {
+	t.Helper()
+	xvfb, err := exec.LookPath("Xvfb")
+	if err != nil {
+		t.Skip("Xvfb not found")
+	}
+
+	// Xvfb picks a free display number and writes it to the file descriptor given by -displayfd
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	cmd := exec.Command(xvfb, "-displayfd", "3", "-nolisten", "tcp", "-screen", "0", fmt.Sprintf("%dx%dx24", width, height))
+	cmd.ExtraFiles = []*os.File{w}
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	display := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(r).ReadString('\n')
+		display <- strings.TrimSpace(line)
+	}()
+	select {
+	case number := <-display:
+		if number == "" {
+			t.Fatal("Xvfb exited without a display")
+		}
+		setEnv(t, "DISPLAY", ":"+number)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Xvfb didn't start")
+	}
+	setEnv(t, "XAUTHORITY", filepath.Join(t.TempDir(), "missing"))
+}
+
+// getPixmap reads a pixmap of the root window's depth back from the server.
+func (c *x11Conn) getPixmap(pixmap uint32, width, height int) (*image.RGBA, error) {
+	// GetImage in ZPixmap format with every plane
+	reply, err := c.request(73, 2, x11Request{}.u32(pixmap).u16(0).u16(0).u16(uint16(width)).u16(uint16(height)).u32(0xffffffff))
+	if err != nil {
+		return nil, err
+	}
+
+	format := c.formats[c.screen.depth]
+	bytesPerPixel := int(format.bitsPerPixel) / 8
+	pad := int(format.scanlinePad) / 8
+	stride := (width*bytesPerPixel + pad - 1) / pad * pad
+	data := reply[32:]
+	if len(data) < stride*height {
+		return nil, errors.New("x11 image reply too short")
+	}
+
+	unpack := func(pixel, mask uint32) uint8 {
+		value := (pixel & mask) >> bits.TrailingZeros32(mask)
+		width := bits.OnesCount32(mask)
+		if width < 8 {
+			return uint8(value << (8 - width))
+		}
+		return uint8(value >> (width - 8))
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var pixel uint32
+			for b := 0; b < bytesPerPixel; b++ {
+				shift := 8 * b
+				if c.imageMSB {
+					shift = 8 * (bytesPerPixel - 1 - b)
+				}
+				pixel |= uint32(data[y*stride+x*bytesPerPixel+b]) << shift
+			}
+			img.SetRGBA(x, y, color.RGBA{unpack(pixel, c.screen.red), unpack(pixel, c.screen.green), unpack(pixel, c.screen.blue), 255})
+		}
+	}
+	return img, nil
+}
+
+// TestSetX11Xvfb checks the background against a real X server, where the stub only mirrors this package's reading of the protocol.
+func TestSetX11Xvfb(t *testing.T) {
+	startXvfb(t, 120, 40)
+	file := writeBands(t)
+
+	err := setX11Mode(Stretch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = setX11(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := getX11()
+	if err != nil || got != file {
+		t.Errorf("getX11() = %q, %v", got, err)
+	}
+	matches, err := x11ModeMatches(Stretch)
+	if err != nil || !matches {
+		t.Errorf("x11ModeMatches(Stretch) = %v, %v", matches, err)
+	}
+
+	c, err := dialX11()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+	rootPixmap, err := c.internAtom("_XROOTPMAP_ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pixmap, err := c.getPixmapProperty(rootPixmap)
+	if err != nil || pixmap == 0 {
+		t.Fatalf("_XROOTPMAP_ID = %#x, %v", pixmap, err)
+	}
+	img, err := c.getPixmap(pixmap, 120, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Xvfb has a single monitor, which the bands fill at twice their size
+	for point, want := range map[image.Point]color.RGBA{{10, 5}: green, {30, 35}: green, {60, 20}: red, {100, 5}: blue, {110, 35}: blue} {
+		if got := img.RGBAAt(point.X, point.Y); !nearColor(got, want) {
+			t.Errorf("pixel %v is %v, want %v", point, got, want)
+		}
+	}
+}
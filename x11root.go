@@ -0,0 +1,403 @@
+//go:build linux
+// +build linux
+
+package wallpaper
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"math/bits"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// setX11 sets the root window background of the X display, keeping the current mode.
+// The pixmap is published in _XROOTPMAP_ID and ESETROOT_PMAP_ID so that compositors can use it for transparency.
+func setX11(file string) error {
+	mode := Crop
+	c, err := dialX11()
+	if err != nil {
+		return err
+	}
+
+	current, err := c.getStringProperty("_WALLPAPER_MODE")
+	c.close()
+	if err != nil {
+		return err
+	}
+	if current != "" {
+		// an unknown value keeps the default
+		_ = mode.UnmarshalText([]byte(current))
+	}
+
+	return setX11Background(file, mode)
+}
+
+// setX11Mode redraws the background set by setX11 with mode, or only records mode if there is none.
+func setX11Mode(mode Mode) error {
+	file, err := getX11()
+	if err == nil {
+		return setX11Background(file, mode)
+	}
+
+	c, err := dialX11()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	err = c.setStringProperty("_WALLPAPER_MODE", modeNames[mode])
+	if err != nil {
+		return err
+	}
+	return c.sync()
+}
+
+// getX11 returns the file last set by setX11, which is recorded on the root window.
+func getX11() (string, error) {
+	c, err := dialX11()
+	if err != nil {
+		return "", err
+	}
+	defer c.close()
+
+	file, err := c.getStringProperty("_WALLPAPER_IMAGE")
+	if err != nil {
+		return "", err
+	}
+	if file == "" {
+		return "", errors.New("x11 image not found")
+	}
+	return file, nil
+}
+
+func x11ModeMatches(mode Mode) (bool, error) {
+	c, err := dialX11()
+	if err != nil {
+		return false, err
+	}
+	defer c.close()
+
+	current, err := c.getStringProperty("_WALLPAPER_MODE")
+	return current == modeNames[mode], err
+}
+
+func setX11Background(file string, mode Mode) error {
+	img, err := decodeImage(file)
+	if err != nil {
+		return err
+	}
+
+	c, err := dialX11()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	monitors, err := c.monitors()
+	if err != nil {
+		return err
+	}
+	canvas := renderBackground(img, image.Rect(0, 0, c.screen.width, c.screen.height), monitors, mode)
+
+	pixmap, err := c.putPixmap(canvas)
+	if err != nil {
+		return err
+	}
+
+	rootPixmap, err := c.internAtom("_XROOTPMAP_ID")
+	if err != nil {
+		return err
+	}
+	esetroot, err := c.internAtom("ESETROOT_PMAP_ID")
+	if err != nil {
+		return err
+	}
+
+	// the previous pixmap is kept alive by its disconnected client, which is killed to free it,
+	// but only if both atoms agree so that a pixmap still used by another program is left alone
+	old, err := c.getPixmapProperty(esetroot)
+	if err != nil {
+		return err
+	}
+	if old != 0 {
+		current, err := c.getPixmapProperty(rootPixmap)
+		if err != nil {
+			return err
+		}
+		if current == old {
+			// KillClient
+			_, err = c.send(113, 0, x11Request{}.u32(old))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	value := x11Request{}.u32(pixmap)
+	for _, atom := range []uint32{rootPixmap, esetroot} {
+		err = c.changeProperty(c.screen.root, atom, x11AtomPixmap, 32, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.setStringProperty("_WALLPAPER_IMAGE", file)
+	if err != nil {
+		return err
+	}
+	err = c.setStringProperty("_WALLPAPER_MODE", modeNames[mode])
+	if err != nil {
+		return err
+	}
+
+	// ChangeWindowAttributes with the background pixmap, then ClearArea to redraw the whole window
+	_, err = c.send(2, 0, x11Request{}.u32(c.screen.root).u32(1).u32(pixmap))
+	if err != nil {
+		return err
+	}
+	_, err = c.send(61, 0, x11Request{}.u32(c.screen.root).u32(0).u32(0))
+	if err != nil {
+		return err
+	}
+
+	// SetCloseDownMode RetainPermanent, so that the pixmap outlives the connection
+	_, err = c.send(112, 1, nil)
+	if err != nil {
+		return err
+	}
+	return c.sync()
+}
+
+func (c *x11Conn) getPixmapProperty(atom uint32) (uint32, error) {
+	typ, value, err := c.getProperty(c.screen.root, atom)
+	if err != nil || typ != x11AtomPixmap || len(value) != 4 {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(value), nil
+}
+
+func (c *x11Conn) getStringProperty(name string) (string, error) {
+	atom, err := c.internAtom(name)
+	if err != nil {
+		return "", err
+	}
+
+	_, value, err := c.getProperty(c.screen.root, atom)
+	return string(value), err
+}
+
+func (c *x11Conn) setStringProperty(name, value string) error {
+	atom, err := c.internAtom(name)
+	if err != nil {
+		return err
+	}
+	utf8String, err := c.internAtom("UTF8_STRING")
+	if err != nil {
+		return err
+	}
+
+	return c.changeProperty(c.screen.root, atom, utf8String, 8, []byte(value))
+}
+
+// monitors returns the area of every active RandR CRTC, or the whole screen without RandR.
+func (c *x11Conn) monitors() ([]image.Rectangle, error) {
+	screen := []image.Rectangle{image.Rect(0, 0, c.screen.width, c.screen.height)}
+
+	// QueryExtension
+	name := "RANDR"
+	reply, err := c.request(98, 0, x11Request{}.u16(uint16(len(name))).u16(0).pad([]byte(name)))
+	if err != nil {
+		return nil, err
+	}
+	if reply[8] == 0 {
+		return screen, nil
+	}
+	randr := reply[9]
+
+	// RRQueryVersion, which also tells the server which version the client speaks
+	reply, err = c.request(randr, 0, x11Request{}.u32(1).u32(3))
+	if err != nil {
+		return nil, err
+	}
+	major, minor := binary.LittleEndian.Uint32(reply[8:]), binary.LittleEndian.Uint32(reply[12:])
+	if major < 1 || (major == 1 && minor < 2) {
+		return screen, nil
+	}
+
+	// RRGetScreenResourcesCurrent avoids polling the outputs, but needs 1.3; RRGetScreenResources has the same reply
+	resources := uint8(25)
+	if major == 1 && minor < 3 {
+		resources = 8
+	}
+	reply, err = c.request(randr, resources, x11Request{}.u32(c.screen.root))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := binary.LittleEndian.Uint32(reply[12:])
+	crtcs := int(binary.LittleEndian.Uint16(reply[16:]))
+	if len(reply) < 32+crtcs*4 {
+		return nil, errors.New("x11 screen resources reply too short")
+	}
+
+	var rects []image.Rectangle
+	for i := 0; i < crtcs; i++ {
+		crtc := binary.LittleEndian.Uint32(reply[32+i*4:])
+		// RRGetCrtcInfo
+		info, err := c.request(randr, 20, x11Request{}.u32(crtc).u32(timestamp))
+		if err != nil {
+			return nil, err
+		}
+
+		x, y := int(int16(binary.LittleEndian.Uint16(info[12:]))), int(int16(binary.LittleEndian.Uint16(info[14:])))
+		width, height := int(binary.LittleEndian.Uint16(info[16:])), int(binary.LittleEndian.Uint16(info[18:]))
+		mode := binary.LittleEndian.Uint32(info[20:])
+		rect := image.Rect(x, y, x+width, y+height)
+		if mode == 0 || rect.Empty() || containsRect(rects, rect) {
+			// disabled or mirrored
+			continue
+		}
+		rects = append(rects, rect)
+	}
+
+	if len(rects) == 0 {
+		return screen, nil
+	}
+	return rects, nil
+}
+
+func containsRect(rects []image.Rectangle, rect image.Rectangle) bool {
+	for _, r := range rects {
+		if r == rect {
+			return true
+		}
+	}
+	return false
+}
+
+// renderBackground draws img on every monitor with mode. Span covers the whole screen instead, like Crop.
+func renderBackground(img image.Image, screen image.Rectangle, monitors []image.Rectangle, mode Mode) *image.RGBA {
+	canvas := image.NewRGBA(screen)
+	draw.Draw(canvas, screen, image.Black, image.Point{}, draw.Src)
+
+	if mode == Span {
+		monitors, mode = []image.Rectangle{screen}, Crop
+	}
+
+	bounds := img.Bounds()
+	for _, monitor := range monitors {
+		monitor = monitor.Intersect(screen)
+		width, height := float64(monitor.Dx()), float64(monitor.Dy())
+		imgWidth, imgHeight := float64(bounds.Dx()), float64(bounds.Dy())
+
+		switch mode {
+		case Center:
+			offset := image.Pt((bounds.Dx()-monitor.Dx())/2, (bounds.Dy()-monitor.Dy())/2)
+			draw.Draw(canvas, monitor, img, bounds.Min.Add(offset), draw.Src)
+		case Crop:
+			xdraw.CatmullRom.Scale(canvas, monitor, img, centerCrop(bounds, monitor.Dx(), monitor.Dy()), draw.Src, nil)
+		case Fit:
+			scale := width / imgWidth
+			if imgHeight*scale > height {
+				scale = height / imgHeight
+			}
+			size := image.Pt(int(imgWidth*scale), int(imgHeight*scale))
+			min := monitor.Min.Add(monitor.Size().Sub(size).Div(2))
+			xdraw.CatmullRom.Scale(canvas, image.Rectangle{min, min.Add(size)}, img, bounds, draw.Src, nil)
+		case Stretch:
+			xdraw.CatmullRom.Scale(canvas, monitor, img, bounds, draw.Src, nil)
+		case Tile:
+			for y := monitor.Min.Y; y < monitor.Max.Y; y += bounds.Dy() {
+				for x := monitor.Min.X; x < monitor.Max.X; x += bounds.Dx() {
+					draw.Draw(canvas, image.Rect(x, y, x+bounds.Dx(), y+bounds.Dy()).Intersect(monitor), img, bounds.Min, draw.Src)
+				}
+			}
+		default:
+			panic("invalid wallpaper mode")
+		}
+	}
+	return canvas
+}
+
+// putPixmap creates a pixmap of the root window's depth and uploads img to it.
+func (c *x11Conn) putPixmap(img *image.RGBA) (uint32, error) {
+	screen := c.screen
+	format, ok := c.formats[screen.depth]
+	// 4 and 5 are TrueColor and DirectColor
+	if !ok || (screen.visualClass != 4 && screen.visualClass != 5) {
+		return 0, errors.New("x11 visual not supported")
+	}
+	bytesPerPixel := int(format.bitsPerPixel) / 8
+	if bytesPerPixel < 2 || bytesPerPixel > 4 || int(format.bitsPerPixel)%8 != 0 {
+		return 0, errors.New("x11 pixel format not supported")
+	}
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	pad := int(format.scanlinePad) / 8
+	stride := (width*bytesPerPixel + pad - 1) / pad * pad
+	// PutImage has a 24 byte header
+	rows := (c.maxRequest - 24) / stride
+	if rows < 1 {
+		return 0, errors.New("x11 screen too wide")
+	}
+
+	pixmap, gc := c.newID(), c.newID()
+	// CreatePixmap
+	_, err := c.send(53, screen.depth, x11Request{}.u32(pixmap).u32(screen.root).u16(uint16(width)).u16(uint16(height)))
+	if err != nil {
+		return 0, err
+	}
+	// CreateGC
+	_, err = c.send(55, 0, x11Request{}.u32(gc).u32(pixmap).u32(0))
+	if err != nil {
+		return 0, err
+	}
+
+	row := make([]byte, stride)
+	for y := 0; y < height; y += rows {
+		n := rows
+		if y+n > height {
+			n = height - y
+		}
+
+		// PutImage in ZPixmap format
+		body := x11Request{}.u32(pixmap).u32(gc).u16(uint16(width)).u16(uint16(n)).u16(0).u16(uint16(y)).u8(0).u8(screen.depth).u16(0)
+		for dy := 0; dy < n; dy++ {
+			for x := 0; x < width; x++ {
+				i := img.PixOffset(img.Rect.Min.X+x, img.Rect.Min.Y+y+dy)
+				pixel := packChannel(img.Pix[i], screen.red) | packChannel(img.Pix[i+1], screen.green) | packChannel(img.Pix[i+2], screen.blue)
+				for b := 0; b < bytesPerPixel; b++ {
+					shift := 8 * b
+					if c.imageMSB {
+						shift = 8 * (bytesPerPixel - 1 - b)
+					}
+					row[x*bytesPerPixel+b] = byte(pixel >> shift)
+				}
+			}
+			body = append(body, row...)
+		}
+
+		_, err = c.send(72, 2, body)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// FreeGC
+	_, err = c.send(60, 0, x11Request{}.u32(gc))
+	return pixmap, err
+}
+
+// packChannel scales an 8-bit channel to the bits of mask.
+func packChannel(v uint8, mask uint32) uint32 {
+	width := bits.OnesCount32(mask)
+	value := uint32(v)
+	if width < 8 {
+		value >>= 8 - width
+	} else {
+		value <<= width - 8
+	}
+	return value << bits.TrailingZeros32(mask) & mask
+}
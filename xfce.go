@@ -25,7 +25,12 @@ func getXFCEProps(key string) ([]string, error) {
 }
 
 func getXFCE() (string, error) {
-	desktops, err := getXFCEProps("last-image")
+	return getXFCEValue("last-image")
+}
+
+// getXFCEValue returns the value of the first property named key.
+func getXFCEValue(key string) (string, error) {
+	desktops, err := getXFCEProps(key)
 	if err != nil || len(desktops) == 0 {
 		return "", err
 	}